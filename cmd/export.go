@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/tanq16/ohara/internal/exporter"
+	"github.com/tanq16/ohara/internal/model"
+)
+
+var exportFlags struct {
+	user   string
+	format string
+	output string
+	filter model.TouchpointFilter
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export touchpoints as CSV, NDJSON, Markdown or iCalendar",
+	Long: `Export touchpoints as CSV, NDJSON, Markdown or iCalendar.
+
+Output goes to stdout unless --output is given, in which case the format
+defaults to the file extension. The filter flags match the query parameters
+of GET /api/touchpoints.`,
+	Args: cobra.NoArgs,
+	Run:  runExport,
+}
+
+func init() {
+	f := &exportFlags.filter
+	exportCmd.Flags().StringVar(&exportFlags.format, "format", "", "Output format (csv, ndjson, md or ics)")
+	exportCmd.Flags().StringVarP(&exportFlags.output, "output", "o", "", "Write to a file instead of stdout")
+	exportCmd.Flags().StringArrayVar(&f.Categories, "category", nil, "Only include this category (repeatable)")
+	exportCmd.Flags().StringArrayVar(&f.Tags, "tag", nil, "Only include this tag (repeatable)")
+	exportCmd.Flags().StringVar(&f.StartDate, "start-date", "", "Only include touchpoints on or after this date")
+	exportCmd.Flags().StringVar(&f.EndDate, "end-date", "", "Only include touchpoints on or before this date")
+	exportCmd.Flags().StringVarP(&f.Search, "search", "q", "", "Full-text search")
+	exportCmd.Flags().StringVar(&f.Query, "query", "", "Query expression")
+	exportCmd.Flags().StringVar(&f.Sort, "sort", "", "Sort by date or category")
+	exportCmd.Flags().StringVar(&f.Order, "order", "", "Sort order (asc or desc)")
+	exportCmd.Flags().StringVar(&exportFlags.user, "user", "", "Work on this account's data instead of the shared namespace")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	name := exportFlags.format
+	if name == "" && exportFlags.output != "" {
+		name = strings.TrimPrefix(filepath.Ext(exportFlags.output), ".")
+	}
+	if name == "" {
+		log.Fatal().Msg("Pass --format, or --output with a file extension")
+	}
+	format, err := exporter.ParseFormat(name)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid format")
+	}
+
+	st, err := openUserStore(exportFlags.user, nil)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize store")
+	}
+	defer closeStore(st)
+
+	var out io.Writer = os.Stdout
+	if exportFlags.output != "" {
+		f, err := os.Create(exportFlags.output)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create output file")
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := exporter.Export(out, format, exportFlags.filter, st.ListTouchpoints); err != nil {
+		log.Fatal().Err(err).Msg("Export failed")
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/tanq16/ohara/internal/importer"
+	"github.com/tanq16/ohara/internal/model"
+)
+
+var importFlags struct {
+	user          string
+	format        string
+	mapping       []string
+	dryRun        bool
+	createMissing bool
+	author        string
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import touchpoints from a CSV, JSON or NDJSON file",
+	Long: `Import touchpoints from a CSV, JSON or NDJSON file.
+
+The format is taken from the file extension unless --format is given. CSV
+files need a header row; columns named after touchpoint fields are picked up
+automatically and others can be mapped with --map field=Column. Tags and
+people in CSV cells are separated by commas or semicolons.
+
+With the json backend, a running server reloads the imported touchpoints
+on its next request, but a change it saves at the same moment as the
+import can be lost; POST /api/import avoids that.
+Webhooks are sent for the import, waiting up to 10 seconds for deliveries,
+but a running server's event streams do not see it.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runImport,
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importFlags.format, "format", "", "File format (csv, json or ndjson)")
+	importCmd.Flags().StringArrayVar(&importFlags.mapping, "map", nil, "Map a touchpoint field to a CSV column, as field=Column (repeatable)")
+	importCmd.Flags().BoolVar(&importFlags.dryRun, "dry-run", false, "Report what would be imported without saving anything")
+	importCmd.Flags().BoolVar(&importFlags.createMissing, "create-missing", false, "Add unknown categories and tags to the metadata")
+	importCmd.Flags().StringVar(&importFlags.author, "author", "import", "Author recorded in revision history")
+	importCmd.Flags().StringVar(&importFlags.user, "user", "", "Work on this account's data instead of the shared namespace")
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	path := args[0]
+
+	format, ok := importer.FormatFromPath(path)
+	if importFlags.format != "" {
+		f, err := importer.ParseFormat(importFlags.format)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid format")
+		}
+		format, ok = f, true
+	}
+	if !ok {
+		log.Fatal().Str("file", path).Msg("Cannot tell the file format from its extension; pass --format")
+	}
+
+	mapping, err := importer.ParseMapping(importFlags.mapping)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid column mapping")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open import file")
+	}
+	defer f.Close()
+
+	rows, err := importer.Parse(f, format, mapping)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read import file")
+	}
+
+	webhooks := newWebhooks()
+	defer webhooks.CloseAfter(webhookGrace)
+	st, err := openUserStore(importFlags.user, webhookChanges(webhooks, importFlags.user))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize store")
+	}
+	defer closeStore(st)
+
+	report, err := st.ImportTouchpoints(rows, model.ImportOptions{
+		DryRun:        importFlags.dryRun,
+		CreateMissing: importFlags.createMissing,
+	}, importFlags.author)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Import failed")
+	}
+	printImportReport(report)
+}
+
+func printImportReport(r model.ImportReport) {
+	verb := "Imported"
+	if r.DryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d of %d touchpoints\n", verb, r.Imported, r.Total)
+	for _, c := range r.CreatedCategories {
+		fmt.Printf("  new category: %s\n", c)
+	}
+	for _, t := range r.CreatedTags {
+		fmt.Printf("  new tag: %s\n", t)
+	}
+	for _, f := range r.Failed {
+		fmt.Printf("  row %d: %s\n", f.Row, f.Error)
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/tanq16/ohara/internal/server"
+)
+
+var mcpFlags struct {
+	user     string
+	author   string
+	readOnly bool
+}
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve the Model Context Protocol over stdio for AI assistants",
+	Long: `Serve the Model Context Protocol over stdin and stdout, so an AI assistant
+can search and log touchpoints and generate reports with Ohara's tools.
+
+Register it with the assistant as a command such as
+"ohara mcp --data-dir /path/to/data". A running server offers the same
+tools over HTTP at /mcp.
+
+With the JSON backend, a server using the same data directory picks up
+changes made here, but a save made at the same moment as one of the
+server's can be lost; connect the assistant to the server's /mcp endpoint
+instead, or use --backend sqlite. Changes made here are sent to webhooks,
+but not to a running server's event streams.`,
+	Args: cobra.NoArgs,
+	Run:  runMCP,
+}
+
+func init() {
+	mcpCmd.Flags().StringVar(&mcpFlags.user, "user", "", "Work on this account's data instead of the shared namespace")
+	mcpCmd.Flags().StringVar(&mcpFlags.author, "author", "mcp", "Name recorded in touchpoint history for changes")
+	mcpCmd.Flags().BoolVar(&mcpFlags.readOnly, "read-only", false, "Only offer the tools that read data")
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func runMCP(cmd *cobra.Command, args []string) {
+	webhooks := newWebhooks()
+	defer webhooks.CloseAfter(webhookGrace)
+	st, err := openUserStore(mcpFlags.user, webhookChanges(webhooks, mcpFlags.user))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize store")
+	}
+	defer closeStore(st)
+
+	srv := server.NewMCP(st, server.MCPOptions{
+		Version:  AppVersion,
+		Author:   mcpFlags.author,
+		ReadOnly: mcpFlags.readOnly,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Run(ctx, &mcp.StdioTransport{}); err != nil && ctx.Err() == nil {
+		log.Error().Err(err).Msg("MCP server error")
+		// os.Exit skips deferred calls, so close everything first.
+		stop()
+		webhooks.CloseAfter(webhookGrace)
+		closeStore(st)
+		os.Exit(1)
+	}
+}
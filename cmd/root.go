@@ -1,25 +1,54 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	stdlog "log"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
+	"github.com/tanq16/ohara/internal/auth"
 	"github.com/tanq16/ohara/internal/server"
 	"github.com/tanq16/ohara/internal/store"
+	"github.com/tanq16/ohara/internal/webhook"
 )
 
 var AppVersion = "dev-build"
 
-var debugFlag bool
+var (
+	debugFlag bool
+	logFormat string
+)
 
 var serveFlags struct {
-	dataDir string
-	port    int
+	dataDir        string
+	port           int
+	backend        string
+	trashRetention time.Duration
+	futureTol      time.Duration
+	sessionTTL     time.Duration
+	publicURL      string
+	metrics        bool
+	webhookLocal   bool
+	oidc           server.OIDCConfig
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	idleTimeout    time.Duration
+	shutdownTime   time.Duration
+	tlsCert        string
+	tlsKey         string
+	tlsSelfSigned  bool
+	tlsHosts       []string
 }
 
 var rootCmd = &cobra.Command{
@@ -35,19 +64,54 @@ var rootCmd = &cobra.Command{
 func init() {
 	cobra.OnInitialize(setupLogs)
 	rootCmd.PersistentFlags().BoolVar(&debugFlag, "debug", false, "Enable debug logging")
-	rootCmd.Flags().StringVar(&serveFlags.dataDir, "data-dir", "./data", "Path to data directory")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "console", "Log output format (console or json)")
+	rootCmd.PersistentFlags().StringVar(&serveFlags.dataDir, "data-dir", "./data", "Path to data directory")
+	rootCmd.PersistentFlags().StringVar(&serveFlags.backend, "backend", "json", "Storage backend (json or sqlite)")
+	rootCmd.PersistentFlags().DurationVar(&serveFlags.futureTol, "future-tolerance", 24*time.Hour, "How far in the future a touchpoint date may be")
+	rootCmd.PersistentFlags().BoolVar(&serveFlags.webhookLocal, "webhook-allow-local", false, "Let webhooks deliver to loopback and link-local addresses")
 	rootCmd.Flags().IntVar(&serveFlags.port, "port", 8080, "Server port")
+	rootCmd.Flags().DurationVar(&serveFlags.trashRetention, "trash-retention", 30*24*time.Hour, "How long deleted touchpoints stay in the trash (0 keeps them forever)")
+	rootCmd.Flags().DurationVar(&serveFlags.sessionTTL, "session-ttl", 7*24*time.Hour, "How long a browser login lasts")
+	rootCmd.Flags().BoolVar(&serveFlags.metrics, "metrics", false, "Serve Prometheus metrics at /metrics, without authentication")
+	rootCmd.Flags().StringVar(&serveFlags.publicURL, "public-url", "", "Scheme and host clients reach the server at, such as https://ohara.example.com, for share links and the OIDC redirect (default taken from each request)")
+	rootCmd.Flags().StringVar(&serveFlags.oidc.Issuer, "oidc-issuer", "", "OpenID Connect issuer URL; enables single sign-on")
+	rootCmd.Flags().StringVar(&serveFlags.oidc.ClientID, "oidc-client-id", "", "OpenID Connect client ID")
+	rootCmd.Flags().StringVar(&serveFlags.oidc.ClientSecret, "oidc-client-secret", "", "OpenID Connect client secret, if the client is confidential (or set OHARA_OIDC_CLIENT_SECRET)")
+	rootCmd.Flags().StringVar(&serveFlags.oidc.RedirectURL, "oidc-redirect-url", "", "Callback URL registered with the provider (default derived from --public-url or the request host)")
+	rootCmd.Flags().StringSliceVar(&serveFlags.oidc.AllowedDomains, "oidc-allowed-domains", nil, "Email domains allowed to sign in through OpenID Connect (default any)")
+	rootCmd.Flags().DurationVar(&serveFlags.readTimeout, "read-timeout", 30*time.Second, "Longest time to read a request, body included (0 for no limit)")
+	rootCmd.Flags().DurationVar(&serveFlags.writeTimeout, "write-timeout", 60*time.Second, "Longest time to write a response; exports are exempt (0 for no limit)")
+	rootCmd.Flags().DurationVar(&serveFlags.idleTimeout, "idle-timeout", 2*time.Minute, "How long idle keep-alive connections stay open")
+	rootCmd.Flags().DurationVar(&serveFlags.shutdownTime, "shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests on SIGINT/SIGTERM")
+	rootCmd.Flags().StringVar(&serveFlags.tlsCert, "tls-cert", "", "PEM certificate file; serves HTTPS together with --tls-key")
+	rootCmd.Flags().StringVar(&serveFlags.tlsKey, "tls-key", "", "PEM private key file for --tls-cert")
+	rootCmd.Flags().BoolVar(&serveFlags.tlsSelfSigned, "tls-self-signed", false, "Serve HTTPS with a self-signed certificate kept in <data-dir>/tls")
+	rootCmd.Flags().StringSliceVar(&serveFlags.tlsHosts, "tls-hosts", nil, "Extra host names or IPs for the self-signed certificate")
 	rootCmd.SetHelpCommand(&cobra.Command{Hidden: true})
 }
 
 func setupLogs() {
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	output := zerolog.ConsoleWriter{
-		Out:        os.Stderr,
-		TimeFormat: time.DateTime,
-		NoColor:    false,
+	var output io.Writer
+	switch logFormat {
+	case "console":
+		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+		output = zerolog.ConsoleWriter{
+			Out:        os.Stderr,
+			TimeFormat: time.DateTime,
+			NoColor:    false,
+		}
+	case "json":
+		// One object per line, for log aggregators.
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+		output = os.Stderr
+	default:
+		fmt.Fprintf(os.Stderr, "unknown log format %q (expected console or json)\n", logFormat)
+		os.Exit(1)
 	}
 	log.Logger = zerolog.New(output).With().Timestamp().Logger()
+	// Anything written through the standard library's logger ends up here too.
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(log.Logger)
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	if debugFlag {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
@@ -61,21 +125,182 @@ func Execute() {
 	}
 }
 
+// parsePublicURL checks --public-url and returns it as scheme://host, or
+// empty if it is not set.
+func parsePublicURL(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https URL", raw)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("%q must be only a scheme and host, as Ohara is served from the root", raw)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// openUserStore opens user's data, or the shared namespace if user is empty.
+func openUserStore(user string, onChange func(typ string, data any)) (server.Storer, error) {
+	if user == "" {
+		return openStoreAt(serveFlags.dataDir, onChange)
+	}
+	if _, err := auth.NewUserStore(serveFlags.dataDir).Get(user); err != nil {
+		return nil, err
+	}
+	return openStoreAt(auth.UserDataDir(serveFlags.dataDir, user), onChange)
+}
+
+// openStoreAt opens the configured backend over dataDir, which is the data
+// directory itself or an account's directory within it, telling onChange
+// about every change if it is set.
+func openStoreAt(dataDir string, onChange func(typ string, data any)) (server.Storer, error) {
+	cfg := store.Config{
+		DataDir:         dataDir,
+		FutureTolerance: serveFlags.futureTol,
+		OnChange:        onChange,
+	}
+	switch serveFlags.backend {
+	case "json":
+		return store.New(cfg)
+	case "sqlite":
+		return store.NewSQLite(cfg)
+	default:
+		return nil, fmt.Errorf("unknown backend %q (expected json or sqlite)", serveFlags.backend)
+	}
+}
+
+// closeStore closes st if its backend holds resources, such as a database.
+func closeStore(st server.Storer) {
+	if c, ok := st.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// webhookGrace is how long commands other than the server wait for their
+// webhook deliveries before exiting.
+const webhookGrace = 10 * time.Second
+
+func newWebhooks() *webhook.Dispatcher {
+	return webhook.New(serveFlags.dataDir, webhook.Options{
+		UserAgent:  "ohara-webhook/" + AppVersion,
+		AllowLocal: serveFlags.webhookLocal,
+	})
+}
+
+// webhookChanges sends the changes a command makes to user's namespace to
+// its webhooks. A server does this itself, along with its event streams,
+// which commands running separately from it cannot reach.
+func webhookChanges(d *webhook.Dispatcher, user string) func(typ string, data any) {
+	return func(typ string, data any) {
+		if err := d.Publish(user, webhook.NewEvent(typ, data)); err != nil {
+			log.Error().Err(err).Str("event", typ).Msg("Failed to queue webhook deliveries")
+		}
+	}
+}
+
 func runServe(cmd *cobra.Command, args []string) {
-	st, err := store.New(store.Config{DataDir: serveFlags.dataDir})
+	tlsCert, tlsKey := serveFlags.tlsCert, serveFlags.tlsKey
+	switch {
+	case serveFlags.tlsSelfSigned && (tlsCert != "" || tlsKey != ""):
+		log.Fatal().Msg("--tls-self-signed cannot be combined with --tls-cert/--tls-key")
+	case (tlsCert == "") != (tlsKey == ""):
+		log.Fatal().Msg("--tls-cert and --tls-key must be given together")
+	case serveFlags.tlsSelfSigned:
+		var err error
+		tlsCert, tlsKey, err = server.SelfSignedCert(filepath.Join(serveFlags.dataDir, "tls"), serveFlags.tlsHosts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to set up self-signed certificate")
+		}
+	}
+
+	webhooks := newWebhooks()
+	events := server.NewEvents(webhooks)
+	st, err := openStoreAt(serveFlags.dataDir, events.Hook(""))
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize store")
 	}
+	defer closeStore(st)
+
+	tokens := auth.NewTokenStore(serveFlags.dataDir)
+	users := auth.NewUserStore(serveFlags.dataDir)
+	hasTokens, err := tokens.Enabled()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load API tokens")
+	}
+	hasUsers, err := users.Enabled()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load users")
+	}
+	if !hasTokens && !hasUsers && serveFlags.oidc.Issuer == "" {
+		log.Warn().Str("package", "cmd").Msg("No API tokens or users exist, so the API is open; create one with `ohara token create` or `ohara user add`")
+	}
+
+	publicURL, err := parsePublicURL(serveFlags.publicURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --public-url")
+	}
+	if publicURL == "" && serveFlags.oidc.Issuer != "" && serveFlags.oidc.RedirectURL == "" {
+		log.Warn().Str("package", "cmd").Msg("Without --public-url or --oidc-redirect-url, the OIDC redirect URL follows each request's Host header")
+	}
 
-	srv := server.New(server.Config{Port: serveFlags.port}, st)
+	var oidcCfg *server.OIDCConfig
+	if serveFlags.oidc.Issuer != "" {
+		if serveFlags.oidc.ClientID == "" {
+			log.Fatal().Msg("--oidc-client-id is required with --oidc-issuer")
+		}
+		cfg := serveFlags.oidc
+		if cfg.ClientSecret == "" {
+			cfg.ClientSecret = os.Getenv("OHARA_OIDC_CLIENT_SECRET")
+		}
+		oidcCfg = &cfg
+	}
+
+	srv := server.New(server.Config{
+		Port:           serveFlags.port,
+		Version:        AppVersion,
+		TrashRetention: serveFlags.trashRetention,
+		Tokens:         tokens,
+		Users:          users,
+		SessionTTL:     serveFlags.sessionTTL,
+		PublicURL:      publicURL,
+		Metrics:        serveFlags.metrics,
+		OpenUserStore: func(user string) (server.Storer, error) {
+			return openStoreAt(auth.UserDataDir(serveFlags.dataDir, user), events.Hook(user))
+		},
+		OIDC:            oidcCfg,
+		Shares:          auth.NewShareStore(serveFlags.dataDir),
+		Webhooks:        webhooks,
+		Events:          events,
+		ReadTimeout:     serveFlags.readTimeout,
+		WriteTimeout:    serveFlags.writeTimeout,
+		IdleTimeout:     serveFlags.idleTimeout,
+		ShutdownTimeout: serveFlags.shutdownTime,
+		TLSCert:         tlsCert,
+		TLSKey:          tlsKey,
+	}, st)
 
 	log.Info().
 		Str("package", "cmd").
 		Int("port", serveFlags.port).
 		Str("data", serveFlags.dataDir).
+		Str("backend", serveFlags.backend).
+		Bool("tls", tlsCert != "").
 		Msg("Starting Ohara")
 
-	if err := srv.Run(); err != nil {
-		log.Fatal().Err(err).Msg("Server error")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Run(ctx); err != nil {
+		log.Error().Err(err).Msg("Server error")
+		// os.Exit skips deferred calls, so close everything first.
+		stop()
+		webhooks.Close()
+		events.Close()
+		closeStore(st)
+		os.Exit(1)
 	}
 }
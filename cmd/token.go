@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/tanq16/ohara/internal/auth"
+)
+
+var tokenFlags struct {
+	name    string
+	user    string
+	scope   string
+	expires time.Duration
+}
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage API tokens",
+	Long: `Manage API tokens.
+
+Once any token or user exists, every /api request needs a login session or
+an Authorization: Bearer header with a token whose scope covers it: read for viewing, write for
+changing touchpoints and reports, and admin for metadata and emptying the
+trash. Tokens are stored hashed in the data directory and take effect on a
+running server straight away. A token created with --user reaches that
+user's data; otherwise it reaches the shared namespace.`,
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a token and print it once",
+	Args:  cobra.NoArgs,
+	Run:   runTokenCreate,
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tokens",
+	Args:  cobra.NoArgs,
+	Run:   runTokenList,
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke a token",
+	Args:  cobra.ExactArgs(1),
+	Run:   runTokenRevoke,
+}
+
+func init() {
+	tokenCreateCmd.Flags().StringVar(&tokenFlags.name, "name", "", "Name for the token, recorded as the author of its changes")
+	tokenCreateCmd.Flags().StringVar(&tokenFlags.user, "user", "", "User whose data the token reaches (default the shared namespace)")
+	tokenCreateCmd.Flags().StringVar(&tokenFlags.scope, "scope", "read", "Token scope (read, write or admin)")
+	tokenCreateCmd.Flags().DurationVar(&tokenFlags.expires, "expires", 0, "How long the token is valid (0 never expires)")
+	tokenCreateCmd.MarkFlagRequired("name")
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+	rootCmd.AddCommand(tokenCmd)
+}
+
+func runTokenCreate(cmd *cobra.Command, args []string) {
+	scope, err := auth.ParseScope(tokenFlags.scope)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid scope")
+	}
+	if tokenFlags.user != "" {
+		if _, err := auth.NewUserStore(serveFlags.dataDir).Get(tokenFlags.user); err != nil {
+			log.Fatal().Err(err).Msg("Unknown user")
+		}
+	}
+	secret, t, err := auth.NewTokenStore(serveFlags.dataDir).Create(tokenFlags.name, tokenFlags.user, scope, tokenFlags.expires)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create token")
+	}
+
+	fmt.Printf("Created %s token %s (%s)", t.Scope, t.ID, t.Name)
+	if t.ExpiresAt != "" {
+		fmt.Printf(", expires %s", t.ExpiresAt)
+	}
+	fmt.Printf("\n\n%s\n\nStore it now; it cannot be shown again.\n", secret)
+}
+
+func runTokenList(cmd *cobra.Command, args []string) {
+	tokens, err := auth.NewTokenStore(serveFlags.dataDir).List()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to list tokens")
+	}
+	if len(tokens) == 0 {
+		fmt.Println("No tokens.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tUSER\tSCOPE\tCREATED\tEXPIRES")
+	for _, t := range tokens {
+		expires := t.ExpiresAt
+		if expires == "" {
+			expires = "never"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, cmp.Or(t.User, "-"), t.Scope, t.CreatedAt, expires)
+	}
+	w.Flush()
+}
+
+func runTokenRevoke(cmd *cobra.Command, args []string) {
+	if err := auth.NewTokenStore(serveFlags.dataDir).Revoke(args[0]); err != nil {
+		log.Fatal().Err(err).Msg("Failed to revoke token")
+	}
+	fmt.Printf("Revoked token %s\n", args[0])
+}
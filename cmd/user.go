@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/tanq16/ohara/internal/auth"
+	"github.com/tanq16/ohara/internal/store"
+)
+
+var userFlags struct {
+	passwordStdin bool
+	adopt         bool
+	issuer        string
+	subject       string
+}
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage user accounts",
+	Long: `Manage user accounts.
+
+Each user signs in to the web UI with a password and gets their own
+touchpoints, metadata, reports and templates under <data-dir>/users/<name>/.
+Once any user exists the API requires a login session or an API token.
+Accounts are stored in <data-dir>/users.json with bcrypt-hashed passwords and
+changes take effect on a running server straight away.`,
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a user",
+	Long: `Add a user.
+
+With --adopt, the data already in the data directory (from before accounts
+existed) is moved into the new user's namespace. Stop the server first when
+adopting data.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runUserAdd,
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	Run:   runUserList,
+}
+
+var userPasswdCmd = &cobra.Command{
+	Use:   "passwd <name>",
+	Short: "Change a user's password",
+	Args:  cobra.ExactArgs(1),
+	Run:   runUserPasswd,
+}
+
+var userLinkCmd = &cobra.Command{
+	Use:   "link <name>",
+	Short: "Link a user to an OpenID Connect identity",
+	Long: `Link a user to an OpenID Connect identity.
+
+Signing in through OIDC starts a session for the user linked to the ID
+token's issuer and subject, and creates a user the first time an identity is
+seen. Link an existing user, such as one made with --adopt, to keep their
+data when they switch to single sign-on.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runUserLink,
+}
+
+var userRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a user, keeping their data on disk",
+	Args:  cobra.ExactArgs(1),
+	Run:   runUserRemove,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{userAddCmd, userPasswdCmd} {
+		c.Flags().BoolVar(&userFlags.passwordStdin, "password-stdin", false, "Read the password from stdin instead of prompting")
+	}
+	userAddCmd.Flags().BoolVar(&userFlags.adopt, "adopt", false, "Move the existing shared data into the new user's namespace")
+	userLinkCmd.Flags().StringVar(&userFlags.issuer, "issuer", "", "OpenID Connect issuer URL, as given to --oidc-issuer")
+	userLinkCmd.Flags().StringVar(&userFlags.subject, "subject", "", "Subject (sub claim) of the identity")
+	userLinkCmd.MarkFlagRequired("issuer")
+	userLinkCmd.MarkFlagRequired("subject")
+	userCmd.AddCommand(userAddCmd, userListCmd, userPasswdCmd, userLinkCmd, userRemoveCmd)
+	rootCmd.AddCommand(userCmd)
+}
+
+// readPassword reads a new password from stdin or, on a terminal, prompts
+// for it twice without echoing.
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if userFlags.passwordStdin || !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(second) {
+		return "", errors.New("passwords do not match")
+	}
+	return string(first), nil
+}
+
+func runUserAdd(cmd *cobra.Command, args []string) {
+	name := args[0]
+	if err := auth.ValidateUsername(name); err != nil {
+		log.Fatal().Err(err).Msg("Invalid username")
+	}
+	password, err := readPassword()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read password")
+	}
+
+	users := auth.NewUserStore(serveFlags.dataDir)
+	if _, err := users.Add(name, password); err != nil {
+		log.Fatal().Err(err).Msg("Failed to add user")
+	}
+	fmt.Printf("Added user %s\n", name)
+
+	if userFlags.adopt {
+		dir := auth.UserDataDir(serveFlags.dataDir, name)
+		n, err := store.MoveData(serveFlags.dataDir, dir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to move existing data")
+		}
+		fmt.Printf("Moved %d data files into %s\n", n, dir)
+	}
+}
+
+func runUserList(cmd *cobra.Command, args []string) {
+	users, err := auth.NewUserStore(serveFlags.dataDir).List()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to list users")
+	}
+	if len(users) == 0 {
+		fmt.Println("No users.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tPASSWORD\tOIDC SUBJECT")
+	for _, u := range users {
+		password := "yes"
+		if u.PasswordHash == "" {
+			password = "no"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Name, u.CreatedAt, password, cmp.Or(u.Subject, "-"))
+	}
+	w.Flush()
+}
+
+func runUserPasswd(cmd *cobra.Command, args []string) {
+	password, err := readPassword()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read password")
+	}
+	if err := auth.NewUserStore(serveFlags.dataDir).SetPassword(args[0], password); err != nil {
+		log.Fatal().Err(err).Msg("Failed to change password")
+	}
+	fmt.Printf("Changed password for %s\n", args[0])
+}
+
+func runUserLink(cmd *cobra.Command, args []string) {
+	if err := auth.NewUserStore(serveFlags.dataDir).Link(args[0], userFlags.issuer, userFlags.subject); err != nil {
+		log.Fatal().Err(err).Msg("Failed to link user")
+	}
+	fmt.Printf("Linked %s to %s at %s\n", args[0], userFlags.subject, userFlags.issuer)
+}
+
+func runUserRemove(cmd *cobra.Command, args []string) {
+	if err := auth.NewUserStore(serveFlags.dataDir).Remove(args[0]); err != nil {
+		log.Fatal().Err(err).Msg("Failed to remove user")
+	}
+	fmt.Printf("Removed user %s; their data remains in %s\n", args[0], auth.UserDataDir(serveFlags.dataDir, args[0]))
+}
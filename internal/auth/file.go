@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// jsonFile caches a list kept as JSON in the data directory. It is re-read
+// whenever the file changes on disk, so changes made with the CLI apply to
+// a running server. Callers serialise access themselves.
+type jsonFile[T any] struct {
+	path    string
+	modTime time.Time
+	items   []T
+}
+
+// load returns the current items, or none if the file does not exist yet.
+func (f *jsonFile[T]) load() ([]T, error) {
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		f.items, f.modTime = nil, time.Time{}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.items != nil && info.ModTime().Equal(f.modTime) {
+		return f.items, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	items := []T{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", f.path, err)
+	}
+	f.items, f.modTime = items, info.ModTime()
+	return items, nil
+}
+
+// save writes items atomically with owner-only permissions, since the file
+// holds credentials.
+func (f *jsonFile[T]) save(items []T) error {
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	// Force a re-read next time so the cached mtime is the file's own.
+	f.items, f.modTime = nil, time.Time{}
+	return nil
+}
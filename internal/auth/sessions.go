@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+type session struct {
+	user    string
+	expires time.Time
+}
+
+// Sessions tracks browser logins in memory, so restarting the server signs
+// everyone out.
+type Sessions struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{ttl: ttl, sessions: map[string]session{}}
+}
+
+// Create starts a session for user and returns its ID and expiry.
+func (s *Sessions) Create(user string) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw)
+	now := time.Now()
+	expires := now.Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.sessions {
+		if !now.Before(v.expires) {
+			delete(s.sessions, k)
+		}
+	}
+	s.sessions[id] = session{user: user, expires: expires}
+	return id, expires, nil
+}
+
+// Lookup returns the user a live session belongs to.
+func (s *Sessions) Lookup(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.sessions[id]
+	if !ok {
+		return "", false
+	}
+	if !time.Now().Before(v.expires) {
+		delete(s.sessions, id)
+		return "", false
+	}
+	return v.user, true
+}
+
+func (s *Sessions) Revoke(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
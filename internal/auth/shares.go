@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// shareRecord is a share link and the namespace it reads from.
+type shareRecord struct {
+	model.Share
+	Owner string `json:"owner,omitempty"`
+}
+
+// ShareStore keeps share links in <data-dir>/shares.json. Links carry an
+// HMAC signature over their ID and expiry, keyed by <data-dir>/share.key;
+// deleting the key invalidates every link.
+type ShareStore struct {
+	keyPath string
+
+	mu   sync.Mutex
+	file jsonFile[shareRecord]
+	key  []byte
+}
+
+func NewShareStore(dataDir string) *ShareStore {
+	return &ShareStore{
+		keyPath: filepath.Join(dataDir, "share.key"),
+		file:    jsonFile[shareRecord]{path: filepath.Join(dataDir, "shares.json")},
+	}
+}
+
+// signingKey loads the key, creating it on first use. Callers must hold mu.
+func (ss *ShareStore) signingKey() ([]byte, error) {
+	if ss.key != nil {
+		return ss.key, nil
+	}
+	key, err := os.ReadFile(ss.keyPath)
+	if os.IsNotExist(err) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(ss.keyPath), 0755); err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(ss.keyPath, key, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ss.key = key
+	return key, nil
+}
+
+func (ss *ShareStore) sign(id string, expires int64) (string, error) {
+	key, err := ss.signingKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// token is the part of a share URL that identifies and authorises it:
+// <id>.<expiry unix time>.<signature>. Callers must hold mu.
+func (ss *ShareStore) token(s model.Share) (string, error) {
+	exp, err := time.Parse(time.RFC3339, s.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	sig, err := ss.sign(s.ID, exp.Unix())
+	if err != nil {
+		return "", err
+	}
+	return s.ID + "." + strconv.FormatInt(exp.Unix(), 10) + "." + sig, nil
+}
+
+// Create saves a share link reading from owner's namespace and returns it
+// along with its token.
+func (ss *ShareStore) Create(owner string, s model.Share, ttl time.Duration) (model.Share, string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return model.Share{}, "", err
+	}
+	now := time.Now().UTC()
+	s.ID = hex.EncodeToString(id)
+	s.CreatedAt = now.Format(time.RFC3339)
+	s.ExpiresAt = now.Add(ttl).Format(time.RFC3339)
+	s.URL = ""
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	records, err := ss.file.load()
+	if err != nil {
+		return model.Share{}, "", err
+	}
+	token, err := ss.token(s)
+	if err != nil {
+		return model.Share{}, "", err
+	}
+	if err := ss.file.save(append(slices.Clone(records), shareRecord{Share: s, Owner: owner})); err != nil {
+		return model.Share{}, "", err
+	}
+	return s, token, nil
+}
+
+// List returns owner's share links, expired ones included, with their
+// tokens in the same order.
+func (ss *ShareStore) List(owner string) ([]model.Share, []string, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	records, err := ss.file.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	shares, tokens := []model.Share{}, []string{}
+	for _, r := range records {
+		if r.Owner != owner {
+			continue
+		}
+		token, err := ss.token(r.Share)
+		if err != nil {
+			return nil, nil, err
+		}
+		shares = append(shares, r.Share)
+		tokens = append(tokens, token)
+	}
+	return shares, tokens, nil
+}
+
+// Revoke deletes one of owner's share links.
+func (ss *ShareStore) Revoke(owner, id string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	records, err := ss.file.load()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(records, func(r shareRecord) bool { return r.ID == id && r.Owner == owner })
+	if i < 0 {
+		return ErrNotFound
+	}
+	return ss.file.save(slices.Delete(slices.Clone(records), i, i+1))
+}
+
+// RevokeReport deletes owner's links to a report, so that another report
+// saved under its name later is not shared by them.
+func (ss *ShareStore) RevokeReport(owner, filename string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	records, err := ss.file.load()
+	if err != nil {
+		return err
+	}
+	kept := slices.DeleteFunc(slices.Clone(records), func(r shareRecord) bool {
+		return r.Report == filename && r.Owner == owner
+	})
+	if len(kept) == len(records) {
+		return nil
+	}
+	return ss.file.save(kept)
+}
+
+// Resolve checks a token's signature and expiry and returns the share and
+// the namespace it reads from. Revoked, expired and forged links all give
+// ErrInvalidToken.
+func (ss *ShareStore) Resolve(token string) (model.Share, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return model.Share{}, "", ErrInvalidToken
+	}
+	id := parts[0]
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return model.Share{}, "", ErrInvalidToken
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	want, err := ss.sign(id, expires)
+	if err != nil {
+		return model.Share{}, "", err
+	}
+	if !hmac.Equal([]byte(want), []byte(parts[2])) || !time.Now().Before(time.Unix(expires, 0)) {
+		return model.Share{}, "", ErrInvalidToken
+	}
+
+	records, err := ss.file.load()
+	if err != nil {
+		return model.Share{}, "", err
+	}
+	i := slices.IndexFunc(records, func(r shareRecord) bool { return r.ID == id })
+	if i < 0 {
+		return model.Share{}, "", ErrInvalidToken
+	}
+	return records[i].Share, records[i].Owner, nil
+}
@@ -0,0 +1,181 @@
+// Package auth manages API tokens and the scopes they grant.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrNotFound     = errors.New("not found")
+)
+
+// Scope is what a token may do. Each scope includes the ones before it.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeOrder = []Scope{ScopeRead, ScopeWrite, ScopeAdmin}
+
+func ParseScope(s string) (Scope, error) {
+	if slices.Contains(scopeOrder, Scope(s)) {
+		return Scope(s), nil
+	}
+	return "", fmt.Errorf("unknown scope %q (expected read, write or admin)", s)
+}
+
+// Allows reports whether a token with scope s may act at level required.
+func (s Scope) Allows(required Scope) bool {
+	return slices.Index(scopeOrder, s) >= slices.Index(scopeOrder, required)
+}
+
+// Token is a stored token. Only a hash of the secret is kept.
+type Token struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// User is the account whose data the token reaches; empty means the
+	// shared namespace at the top of the data directory.
+	User      string `json:"user,omitempty"`
+	Scope     Scope  `json:"scope"`
+	Hash      string `json:"hash"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+func (t Token) expired(now time.Time) bool {
+	if t.ExpiresAt == "" {
+		return false
+	}
+	exp, err := time.Parse(time.RFC3339, t.ExpiresAt)
+	return err != nil || !now.Before(exp)
+}
+
+// tokenPrefix marks Ohara tokens so they are easy to spot in config files
+// and secret scanners.
+const tokenPrefix = "ohara_"
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenStore keeps tokens in <data-dir>/tokens.json.
+type TokenStore struct {
+	mu   sync.Mutex
+	file jsonFile[Token]
+}
+
+func NewTokenStore(dataDir string) *TokenStore {
+	return &TokenStore{file: jsonFile[Token]{path: filepath.Join(dataDir, "tokens.json")}}
+}
+
+// Enabled reports whether any tokens exist. Until one is created the API
+// stays open, as it was before tokens were introduced.
+func (ts *TokenStore) Enabled() (bool, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tokens, err := ts.file.load()
+	return len(tokens) > 0, err
+}
+
+// Create issues a token for user's data and returns its secret, which is
+// not stored and cannot be recovered later. A zero ttl never expires.
+func (ts *TokenStore) Create(name, user string, scope Scope, ttl time.Duration) (string, Token, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", Token{}, fmt.Errorf("token name is required")
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", Token{}, err
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return "", Token{}, err
+	}
+
+	now := time.Now().UTC()
+	t := Token{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		User:      user,
+		Scope:     scope,
+		Hash:      hashToken(secret),
+		CreatedAt: now.Format(time.RFC3339),
+	}
+	if ttl > 0 {
+		t.ExpiresAt = now.Add(ttl).Format(time.RFC3339)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tokens, err := ts.file.load()
+	if err != nil {
+		return "", Token{}, err
+	}
+	if err := ts.file.save(append(slices.Clone(tokens), t)); err != nil {
+		return "", Token{}, err
+	}
+	return secret, t, nil
+}
+
+func (ts *TokenStore) List() ([]Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tokens, err := ts.file.load()
+	return slices.Clone(tokens), err
+}
+
+func (ts *TokenStore) Revoke(id string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tokens, err := ts.file.load()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tokens, func(t Token) bool { return t.ID == id })
+	if i < 0 {
+		return fmt.Errorf("token %s: %w", id, ErrNotFound)
+	}
+	return ts.file.save(slices.Delete(slices.Clone(tokens), i, i+1))
+}
+
+// Verify returns the token a secret belongs to, if it exists and has not
+// expired.
+func (ts *TokenStore) Verify(secret string) (Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return Token{}, ErrInvalidToken
+	}
+	hash := hashToken(secret)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tokens, err := ts.file.load()
+	if err != nil {
+		return Token{}, err
+	}
+	for _, t := range tokens {
+		if t.Hash == hash {
+			if t.expired(time.Now()) {
+				return Token{}, ErrInvalidToken
+			}
+			return t, nil
+		}
+	}
+	return Token{}, ErrInvalidToken
+}
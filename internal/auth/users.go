@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrAlreadyExists      = errors.New("already exists")
+)
+
+// validUsername keeps names usable as directory names on any platform.
+var validUsername = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+const minPasswordLength = 8
+
+// User is an account with its own touchpoints, metadata and reports.
+type User struct {
+	Name string `json:"name"`
+	// PasswordHash is empty for accounts that only sign in through OIDC.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// Issuer and Subject link the account to an OpenID Connect identity.
+	Issuer    string `json:"oidc_issuer,omitempty"`
+	Subject   string `json:"oidc_subject,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// UserDataDir is where a user's data lives within the data directory.
+func UserDataDir(dataDir, name string) string {
+	return filepath.Join(dataDir, "users", name)
+}
+
+func ValidateUsername(name string) error {
+	if !validUsername.MatchString(name) {
+		return fmt.Errorf("invalid username %q (lowercase letters, digits, - and _, up to 32 characters)", name)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// UserStore keeps accounts in <data-dir>/users.json.
+type UserStore struct {
+	mu   sync.Mutex
+	file jsonFile[User]
+}
+
+func NewUserStore(dataDir string) *UserStore {
+	return &UserStore{file: jsonFile[User]{path: filepath.Join(dataDir, "users.json")}}
+}
+
+// Enabled reports whether any accounts exist.
+func (us *UserStore) Enabled() (bool, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	users, err := us.file.load()
+	return len(users) > 0, err
+}
+
+func (us *UserStore) List() ([]User, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	users, err := us.file.load()
+	return slices.Clone(users), err
+}
+
+func (us *UserStore) Get(name string) (User, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	users, err := us.file.load()
+	if err != nil {
+		return User{}, err
+	}
+	i := slices.IndexFunc(users, func(u User) bool { return u.Name == name })
+	if i < 0 {
+		return User{}, fmt.Errorf("user %s: %w", name, ErrNotFound)
+	}
+	return users[i], nil
+}
+
+func (us *UserStore) Add(name, password string) (User, error) {
+	if err := ValidateUsername(name); err != nil {
+		return User{}, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	users, err := us.file.load()
+	if err != nil {
+		return User{}, err
+	}
+	if slices.ContainsFunc(users, func(u User) bool { return u.Name == name }) {
+		return User{}, fmt.Errorf("user %s: %w", name, ErrAlreadyExists)
+	}
+	u := User{Name: name, PasswordHash: hash, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	return u, us.file.save(append(slices.Clone(users), u))
+}
+
+// Link ties an account to an OpenID Connect identity, replacing any
+// previous link. Each identity maps to at most one account.
+func (us *UserStore) Link(name, issuer, subject string) error {
+	if issuer == "" || subject == "" {
+		return errors.New("issuer and subject are required")
+	}
+	return us.update(name, func(users []User, i int) []User {
+		for j := range users {
+			if users[j].Issuer == issuer && users[j].Subject == subject {
+				users[j].Issuer, users[j].Subject = "", ""
+			}
+		}
+		users[i].Issuer, users[i].Subject = issuer, subject
+		return users
+	})
+}
+
+// Provision returns the account linked to an OpenID Connect identity,
+// creating one on first sign-in. New accounts are named after hint, made
+// valid and unique.
+func (us *UserStore) Provision(issuer, subject, hint string) (User, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	users, err := us.file.load()
+	if err != nil {
+		return User{}, err
+	}
+	if i := slices.IndexFunc(users, func(u User) bool { return u.Issuer == issuer && u.Subject == subject }); i >= 0 {
+		return users[i], nil
+	}
+
+	base := usernameFrom(hint)
+	name := base
+	for n := 2; slices.ContainsFunc(users, func(u User) bool { return u.Name == name }); n++ {
+		suffix := fmt.Sprintf("-%d", n)
+		name = base[:min(len(base), 32-len(suffix))] + suffix
+	}
+	u := User{Name: name, Issuer: issuer, Subject: subject, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	return u, us.file.save(append(slices.Clone(users), u))
+}
+
+// usernameFrom turns a display name or email local part into a valid
+// username.
+func usernameFrom(hint string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(hint) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		case b.Len() > 0:
+			b.WriteByte('-')
+		}
+		if b.Len() == 32 {
+			break
+		}
+	}
+	name := strings.Trim(b.String(), "-_")
+	if !validUsername.MatchString(name) {
+		return "user"
+	}
+	return name
+}
+
+func (us *UserStore) SetPassword(name, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return us.update(name, func(users []User, i int) []User {
+		users[i].PasswordHash = hash
+		return users
+	})
+}
+
+// Remove deletes an account. Its data directory is left in place.
+func (us *UserStore) Remove(name string) error {
+	return us.update(name, func(users []User, i int) []User {
+		return slices.Delete(users, i, i+1)
+	})
+}
+
+func (us *UserStore) update(name string, fn func(users []User, i int) []User) error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	users, err := us.file.load()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(users, func(u User) bool { return u.Name == name })
+	if i < 0 {
+		return fmt.Errorf("user %s: %w", name, ErrNotFound)
+	}
+	return us.file.save(fn(slices.Clone(users), i))
+}
+
+// dummyHash is compared against when a username is unknown, so a failed
+// login takes as long whether or not the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("ohara-dummy-password"), bcrypt.DefaultCost)
+
+// Authenticate checks a username and password.
+func (us *UserStore) Authenticate(name, password string) (User, error) {
+	u, err := us.Get(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return User{}, err
+	}
+	hash := []byte(u.PasswordHash)
+	if err != nil {
+		hash = dummyHash
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || u.Name == "" {
+		return User{}, ErrInvalidCredentials
+	}
+	return u, nil
+}
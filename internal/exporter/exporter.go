@@ -0,0 +1,294 @@
+// Package exporter writes touchpoints out as CSV, NDJSON, Markdown or
+// iCalendar, one page at a time.
+package exporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+type Format string
+
+const (
+	CSV      Format = "csv"
+	NDJSON   Format = "ndjson"
+	Markdown Format = "md"
+	ICS      Format = "ics"
+)
+
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case CSV, NDJSON, Markdown, ICS:
+		return f, nil
+	case "jsonl":
+		return NDJSON, nil
+	case "markdown":
+		return Markdown, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (expected csv, ndjson, md or ics)", name)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case NDJSON:
+		return "application/x-ndjson"
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	case ICS:
+		return "text/calendar; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// pageSize is how many touchpoints are fetched and written at a time.
+const pageSize = 500
+
+// Lister is the part of the store an export reads from.
+type Lister func(f model.TouchpointFilter) (model.TouchpointPage, error)
+
+// Export writes every touchpoint matching filter to w, following cursors
+// page by page so the full result is never held in memory. Limit and Cursor
+// on the filter are ignored. Nothing is written if the first page fails, so
+// callers can still report a clean error.
+func Export(w io.Writer, f Format, filter model.TouchpointFilter, list Lister) error {
+	filter.Limit = pageSize
+	filter.Cursor = ""
+
+	page, err := list(filter)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := newEncoder(f, bw)
+	if err := enc.begin(); err != nil {
+		return err
+	}
+	for {
+		for _, tp := range page.Touchpoints {
+			if err := enc.write(tp); err != nil {
+				return err
+			}
+		}
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+		if page, err = list(filter); err != nil {
+			return err
+		}
+	}
+	if err := enc.end(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+type encoder interface {
+	begin() error
+	write(tp model.Touchpoint) error
+	flush() error
+	end() error
+}
+
+func newEncoder(f Format, w *bufio.Writer) encoder {
+	switch f {
+	case CSV:
+		return &csvEncoder{w: csv.NewWriter(w)}
+	case Markdown:
+		return &markdownEncoder{w: w}
+	case ICS:
+		return &icsEncoder{w: w, stamp: time.Now().UTC().Format("20060102T150405Z")}
+	default:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}
+	}
+}
+
+// csvColumns match the importer's field names so an export can be
+// imported again as is.
+var csvColumns = []string{"id", "date", "description", "category", "tags", "people_involved", "url"}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) begin() error {
+	return e.w.Write(csvColumns)
+}
+
+func (e *csvEncoder) write(tp model.Touchpoint) error {
+	return e.w.Write([]string{
+		tp.ID,
+		tp.Date,
+		tp.Description,
+		tp.Category,
+		strings.Join(tp.Tags, "; "),
+		strings.Join(tp.PeopleInvolved, "; "),
+		tp.URL,
+	})
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end() error { return nil }
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) begin() error                    { return nil }
+func (e *ndjsonEncoder) write(tp model.Touchpoint) error { return e.enc.Encode(tp) }
+func (e *ndjsonEncoder) flush() error                    { return nil }
+func (e *ndjsonEncoder) end() error                      { return nil }
+
+// markdownEncoder writes a bullet per touchpoint under a heading for each
+// month, starting a new heading whenever the month changes.
+type markdownEncoder struct {
+	w     *bufio.Writer
+	month string
+}
+
+func (e *markdownEncoder) begin() error {
+	_, err := e.w.WriteString("# Touchpoints\n")
+	return err
+}
+
+func (e *markdownEncoder) write(tp model.Touchpoint) error {
+	day, month := tp.Date, ""
+	if t, err := time.Parse(time.RFC3339, tp.Date); err == nil {
+		day, month = t.Format(time.DateOnly), t.Format("January 2006")
+	}
+	if month != e.month {
+		e.month = month
+		fmt.Fprintf(e.w, "\n## %s\n\n", month)
+	}
+
+	fmt.Fprintf(e.w, "- **%s** · %s · %s", day, tp.Category, strings.Join(strings.Fields(tp.Description), " "))
+	if len(tp.Tags) > 0 {
+		fmt.Fprintf(e.w, " · tags: %s", strings.Join(tp.Tags, ", "))
+	}
+	if len(tp.PeopleInvolved) > 0 {
+		fmt.Fprintf(e.w, " · with %s", strings.Join(tp.PeopleInvolved, ", "))
+	}
+	if tp.URL != "" {
+		fmt.Fprintf(e.w, " · <%s>", tp.URL)
+	}
+	_, err := e.w.WriteString("\n")
+	return err
+}
+
+func (e *markdownEncoder) flush() error { return nil }
+func (e *markdownEncoder) end() error   { return nil }
+
+// icsEncoder writes each touchpoint as an all-day event on its UTC date.
+type icsEncoder struct {
+	w     *bufio.Writer
+	stamp string
+}
+
+func (e *icsEncoder) begin() error {
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:-//ohara//touchpoints//EN")
+	e.line("CALSCALE:GREGORIAN")
+	return nil
+}
+
+func (e *icsEncoder) write(tp model.Touchpoint) error {
+	t, err := time.Parse(time.RFC3339, tp.Date)
+	if err != nil {
+		return fmt.Errorf("touchpoint %s: invalid date %q", tp.ID, tp.Date)
+	}
+	t = t.UTC()
+
+	details := tp.Description
+	if len(tp.PeopleInvolved) > 0 {
+		details += "\n\nPeople: " + strings.Join(tp.PeopleInvolved, ", ")
+	}
+	if len(tp.Tags) > 0 {
+		details += "\nTags: " + strings.Join(tp.Tags, ", ")
+	}
+	categories := make([]string, 0, len(tp.Tags)+1)
+	for _, c := range append([]string{tp.Category}, tp.Tags...) {
+		categories = append(categories, icsEscape(c))
+	}
+
+	e.line("BEGIN:VEVENT")
+	e.line("UID:" + tp.ID + "@ohara")
+	e.line("DTSTAMP:" + e.stamp)
+	e.line("DTSTART;VALUE=DATE:" + t.Format("20060102"))
+	e.line("DTEND;VALUE=DATE:" + t.AddDate(0, 0, 1).Format("20060102"))
+	e.line("SUMMARY:" + icsEscape(tp.Category+": "+strings.Join(strings.Fields(tp.Description), " ")))
+	e.line("DESCRIPTION:" + icsEscape(details))
+	e.line("CATEGORIES:" + strings.Join(categories, ","))
+	if tp.URL != "" {
+		e.line("URL:" + icsURI(tp.URL))
+	}
+	e.line("END:VEVENT")
+	return nil
+}
+
+func (e *icsEncoder) flush() error { return nil }
+
+func (e *icsEncoder) end() error {
+	e.line("END:VCALENDAR")
+	return nil
+}
+
+// line writes a content line, folded at 75 octets without splitting UTF-8
+// sequences as RFC 5545 requires.
+func (e *icsEncoder) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		e.w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines lose one octet to the leading space.
+		limit = 74
+	}
+	e.w.WriteString(s + "\r\n")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// icsURI percent-encodes the characters a URI value can't hold. Backslash
+// escapes are for TEXT values only, so calendar clients would keep them as
+// part of the link, but a raw line break would end the property and start
+// a new one.
+func icsURI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == 0x7f || c == '"' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func TestICSURL(t *testing.T) {
+	long := "https://git.example.com/org/repo/pull/1?tab=files&filter=a,b;c#" + strings.Repeat("x", 60)
+	tests := []struct {
+		url, want string
+	}{
+		{"https://git.example.com/pr/1", "https://git.example.com/pr/1"},
+		{long, long},
+		{"https://example.com/a b\r\nX-INJECTED:1", "https://example.com/a%20b%0D%0AX-INJECTED:1"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		tp := model.Touchpoint{ID: "tp1", Date: "2025-01-15T12:00:00Z", Description: "Fixed login bug", Category: "Bug Fix", URL: tt.url}
+		list := func(model.TouchpointFilter) (model.TouchpointPage, error) {
+			return model.TouchpointPage{Touchpoints: []model.Touchpoint{tp}}, nil
+		}
+		if err := Export(&out, ICS, model.TouchpointFilter{}, list); err != nil {
+			t.Fatal(err)
+		}
+
+		// Unfold the content lines before looking for the property, and
+		// check every physical line fits in 75 octets.
+		var url string
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\r\n"), "\r\n")
+		var unfolded []string
+		for _, line := range lines {
+			if len(line) > 75 {
+				t.Errorf("line %q is longer than 75 octets", line)
+			}
+			if rest, ok := strings.CutPrefix(line, " "); ok && len(unfolded) > 0 {
+				unfolded[len(unfolded)-1] += rest
+			} else {
+				unfolded = append(unfolded, line)
+			}
+		}
+		for _, line := range unfolded {
+			if strings.HasPrefix(line, "X-INJECTED") {
+				t.Errorf("URL %q injected a property", tt.url)
+			}
+			if v, ok := strings.CutPrefix(line, "URL:"); ok {
+				url = v
+			}
+		}
+		if url != tt.want {
+			t.Errorf("URL:%s, want URL:%s", url, tt.want)
+		}
+	}
+}
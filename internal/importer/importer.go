@@ -0,0 +1,238 @@
+// Package importer reads touchpoints from CSV, JSON and NDJSON files into
+// rows the store can validate and save.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+)
+
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case CSV, JSON, NDJSON:
+		return f, nil
+	case "jsonl":
+		return NDJSON, nil
+	}
+	return "", fmt.Errorf("unknown import format %q (expected csv, json or ndjson)", name)
+}
+
+// FormatFromPath guesses the format from a file extension.
+func FormatFromPath(path string) (Format, bool) {
+	f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	return f, err == nil
+}
+
+// FormatFromContentType guesses the format from a request Content-Type.
+func FormatFromContentType(contentType string) (Format, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch mt {
+	case "text/csv":
+		return CSV, true
+	case "application/json":
+		return JSON, true
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return NDJSON, true
+	}
+	return "", false
+}
+
+// fields are the touchpoint input fields a CSV column can map to.
+var fields = []string{"date", "description", "category", "tags", "people_involved", "url"}
+
+// Mapping maps touchpoint fields to CSV column headers. Fields without an
+// entry are read from the column of the same name, ignoring case.
+type Mapping map[string]string
+
+// ParseMapping reads field=Column pairs, as given on the command line or
+// in repeated map query parameters.
+func ParseMapping(pairs []string) (Mapping, error) {
+	m := Mapping{}
+	for _, pair := range pairs {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "people" {
+			field = "people_involved"
+		}
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid column mapping %q (expected field=Column)", pair)
+		}
+		if !slices.Contains(fields, field) {
+			return nil, fmt.Errorf("invalid column mapping %q: unknown field %q (expected one of %s)", pair, field, strings.Join(fields, ", "))
+		}
+		m[field] = column
+	}
+	return m, nil
+}
+
+// Parse reads every record from r. Records that cannot be parsed are
+// returned with Error set so they show up in the import report; only
+// problems with the file as a whole are returned as an error. Rows are
+// numbered by line for CSV and NDJSON and by element for JSON arrays.
+func Parse(r io.Reader, f Format, m Mapping) ([]model.ImportRow, error) {
+	switch f {
+	case CSV:
+		return parseCSV(r, m)
+	case JSON:
+		return parseJSON(r)
+	case NDJSON:
+		return parseNDJSON(r)
+	}
+	return nil, fmt.Errorf("unknown import format %q", f)
+}
+
+func parseCSV(r io.Reader, m Mapping) ([]model.ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return []model.ImportRow{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for _, field := range fields {
+		name, mapped := m[field]
+		if !mapped {
+			name = field
+		}
+		idx := -1
+		for i, h := range header {
+			h = strings.TrimSpace(h)
+			if strings.EqualFold(h, name) || (!mapped && field == "people_involved" && strings.EqualFold(h, "people")) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 && mapped {
+			return nil, fmt.Errorf("column %q mapped to %s is not in the CSV header", name, field)
+		}
+		if idx >= 0 {
+			columns[field] = idx
+		}
+	}
+	if _, ok := columns["description"]; !ok {
+		return nil, fmt.Errorf("CSV has no description column (map one with description=Column)")
+	}
+
+	rows := make([]model.ImportRow, 0)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			rows = append(rows, model.ImportRow{Row: pe.StartLine, Error: pe.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		cell := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, model.ImportRow{Row: line, Input: model.TouchpointInput{
+			Date:           cell("date"),
+			Description:    cell("description"),
+			Category:       cell("category"),
+			Tags:           splitList(cell("tags")),
+			PeopleInvolved: splitList(cell("people_involved")),
+			URL:            cell("url"),
+		}})
+	}
+	return rows, nil
+}
+
+// splitList splits a CSV cell holding several values separated by commas
+// or semicolons.
+func splitList(cell string) []string {
+	parts := strings.FieldsFunc(cell, func(r rune) bool { return r == ',' || r == ';' })
+	values := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			values = append(values, p)
+		}
+	}
+	return values
+}
+
+func parseJSON(r io.Reader) ([]model.ImportRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return []model.ImportRow{}, nil
+	}
+	if data[0] != '[' {
+		return nil, fmt.Errorf("JSON import must be an array of touchpoints")
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	rows := make([]model.ImportRow, 0, len(elems))
+	for i, elem := range elems {
+		rows = append(rows, decodeRow(i+1, elem))
+	}
+	return rows, nil
+}
+
+func parseNDJSON(r io.Reader) ([]model.ImportRow, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	rows := make([]model.ImportRow, 0)
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		rows = append(rows, decodeRow(line, text))
+	}
+	return rows, sc.Err()
+}
+
+// decodeRow reads one touchpoint object. Extra fields such as id are
+// ignored so exported touchpoints can be imported again.
+func decodeRow(row int, data []byte) model.ImportRow {
+	var input model.TouchpointInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return model.ImportRow{Row: row, Error: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return model.ImportRow{Row: row, Input: input}
+}
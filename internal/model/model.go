@@ -8,9 +8,13 @@ type Touchpoint struct {
 	Tags           []string `json:"tags"`
 	PeopleInvolved []string `json:"people_involved"`
 	URL            string   `json:"url"`
+	DeletedAt      string   `json:"deleted_at,omitempty"`
 }
 
 type TouchpointInput struct {
+	// Date is optional, as RFC3339 or YYYY-MM-DD. New touchpoints default to
+	// now and updates keep the existing date when it is empty.
+	Date           string   `json:"date,omitempty"`
 	Description    string   `json:"description"`
 	Category       string   `json:"category"`
 	Tags           []string `json:"tags"`
@@ -18,7 +22,257 @@ type TouchpointInput struct {
 	URL            string   `json:"url"`
 }
 
+// TouchpointFilter selects touchpoints to list. Empty fields match everything.
+type TouchpointFilter struct {
+	// Categories and Tags match touchpoints with any of the listed values.
+	Categories []string
+	Tags       []string
+	StartDate  string
+	EndDate    string
+	// Search is a full-text query over description, people and URL: words
+	// must all match, "quoted phrases" match in order and word* matches prefixes.
+	Search string
+	// Query is a boolean expression over fields, e.g.
+	// category:"Bug Fix" AND (tag:backend OR tag:security) AND date>=2025-01-01.
+	Query string
+	// Sort is "date" or "category" and Order is "asc" or "desc". Without a
+	// sort, results come in creation order, or by relevance when searching.
+	Sort  string
+	Order string
+	// Limit caps the page size (0 means no limit); Cursor resumes from a
+	// previous page's NextCursor.
+	Limit  int
+	Cursor string
+}
+
+type TouchpointPage struct {
+	Touchpoints []Touchpoint `json:"touchpoints"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+}
+
+// Counts is how much data a store holds.
+type Counts struct {
+	// Touchpoints does not count the trash.
+	Touchpoints int
+	Trashed     int
+	Reports     int
+}
+
 type Metadata struct {
 	Categories []string `json:"categories"`
 	Tags       []string `json:"tags"`
 }
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+type Revision struct {
+	Rev          int           `json:"rev"`
+	TouchpointID string        `json:"touchpoint_id"`
+	Timestamp    string        `json:"timestamp"`
+	Author       string        `json:"author"`
+	Action       string        `json:"action"`
+	Changes      []FieldChange `json:"changes"`
+	Snapshot     Touchpoint    `json:"snapshot"`
+}
+
+// ImportRow is one record read from an import file. Row locates it in the
+// source for error reporting and Error is set when it could not be parsed.
+type ImportRow struct {
+	Row   int
+	Input TouchpointInput
+	Error string
+}
+
+type ImportOptions struct {
+	// DryRun validates every row and reports the outcome without saving.
+	DryRun bool
+	// CreateMissing adds unknown categories and tags to the metadata
+	// instead of rejecting the rows that use them.
+	CreateMissing bool
+}
+
+type ImportFailure struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun            bool            `json:"dry_run"`
+	Total             int             `json:"total"`
+	Imported          int             `json:"imported"`
+	Failed            []ImportFailure `json:"failed"`
+	CreatedCategories []string        `json:"created_categories"`
+	CreatedTags       []string        `json:"created_tags"`
+}
+
+// ReportRequest asks for a report to be generated from a template over the
+// touchpoints matching its filters.
+type ReportRequest struct {
+	Template string `json:"template"`
+	// Filename defaults to <template>-<timestamp>.md.
+	Filename   string   `json:"filename"`
+	Title      string   `json:"title"`
+	GroupBy    string   `json:"group_by"`
+	StartDate  string   `json:"start_date"`
+	EndDate    string   `json:"end_date"`
+	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+	Search     string   `json:"q"`
+	Query      string   `json:"query"`
+}
+
+// ReportPeriod is the span a report covers. Either end may be YYYY,
+// YYYY-MM, YYYY-MM-DD or RFC3339, and each covers its whole period.
+type ReportPeriod struct {
+	Start string `json:"start,omitempty" yaml:"start"`
+	End   string `json:"end,omitempty" yaml:"end"`
+}
+
+// ReportFrontMatter is the YAML block a report may open with.
+type ReportFrontMatter struct {
+	Title       string        `json:"title" yaml:"title"`
+	Period      *ReportPeriod `json:"period,omitempty" yaml:"period"`
+	Author      string        `json:"author,omitempty" yaml:"author"`
+	Touchpoints []string      `json:"touchpoints,omitempty" yaml:"touchpoints"`
+	// Status is free-form, typically draft or final.
+	Status string `json:"status,omitempty" yaml:"status"`
+}
+
+type ReportInfo struct {
+	Filename string `json:"filename"`
+	ReportFrontMatter
+	Size       int64  `json:"size"`
+	CreatedAt  string `json:"created_at"`
+	ModifiedAt string `json:"modified_at"`
+}
+
+// ReportDocument is a report's Markdown together with its front matter.
+type ReportDocument struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+	ReportFrontMatter
+}
+
+type ReportFilter struct {
+	Statuses []string
+	// StartDate and EndDate select reports whose period overlaps them,
+	// with the same formats as TouchpointFilter.
+	StartDate string
+	EndDate   string
+}
+
+// ShareFilter selects the touchpoints a share link exposes, with the same
+// meaning as the touchpoint list's filters.
+type ShareFilter struct {
+	StartDate  string   `json:"start_date,omitempty"`
+	EndDate    string   `json:"end_date,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Search     string   `json:"q,omitempty"`
+	Query      string   `json:"query,omitempty"`
+}
+
+// Share is a read-only link to a single report, or to the touchpoints
+// matching a filter when Report is empty.
+type Share struct {
+	ID        string       `json:"id"`
+	Title     string       `json:"title,omitempty"`
+	Report    string       `json:"report,omitempty"`
+	Filter    *ShareFilter `json:"filter,omitempty"`
+	CreatedBy string       `json:"created_by"`
+	CreatedAt string       `json:"created_at"`
+	ExpiresAt string       `json:"expires_at"`
+	// URL is the signed link; it is filled in on responses, not stored.
+	URL string `json:"url,omitempty"`
+}
+
+// ShareRequest asks for a share link to a report or a filtered set of
+// touchpoints.
+type ShareRequest struct {
+	Title  string       `json:"title"`
+	Report string       `json:"report"`
+	Filter *ShareFilter `json:"filter"`
+	// ExpiresIn is a duration such as "168h"; it defaults to a week.
+	ExpiresIn string `json:"expires_in"`
+}
+
+// Event types describe changes to a namespace's data.
+const (
+	EventTouchpointCreated   = "touchpoint.created"
+	EventTouchpointUpdated   = "touchpoint.updated"
+	EventTouchpointDeleted   = "touchpoint.deleted"
+	EventTouchpointsImported = "touchpoints.imported"
+	EventMetadataUpdated     = "metadata.updated"
+	EventReportPublished     = "report.published"
+	EventReportUpdated       = "report.updated"
+	EventReportRenamed       = "report.renamed"
+	EventReportDeleted       = "report.deleted"
+	// EventPing is only sent by a webhook test.
+	EventPing = "ping"
+)
+
+// EventTypes are the event types webhooks can subscribe to.
+var EventTypes = []string{
+	EventTouchpointCreated,
+	EventTouchpointUpdated,
+	EventTouchpointDeleted,
+	EventTouchpointsImported,
+	EventMetadataUpdated,
+	EventReportPublished,
+	EventReportUpdated,
+	EventReportRenamed,
+	EventReportDeleted,
+}
+
+// Event is a change to a namespace's data, as sent to webhooks and event
+// streams.
+type Event struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+	Data      any    `json:"data"`
+}
+
+// Webhook is a subscription to events, delivered as signed POST requests.
+type Webhook struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	// Events lists the event types to deliver; empty means all of them.
+	Events    []string `json:"events,omitempty"`
+	CreatedBy string   `json:"created_by"`
+	CreatedAt string   `json:"created_at"`
+	// Secret signs deliveries. It is only returned when the webhook is
+	// created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookRequest asks for a new webhook.
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+	// Secret defaults to a random one.
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery records the attempts to deliver one event to a webhook.
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	EventID   string `json:"event_id"`
+	Event     string `json:"event"`
+	// Status is "pending" while attempts remain, then "succeeded" or
+	// "failed".
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// ResponseStatus is the receiver's last HTTP status, if it answered.
+	ResponseStatus int    `json:"response_status,omitempty"`
+	Error          string `json:"error,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
@@ -0,0 +1,159 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/tanq16/ohara/internal/model"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdown renders GitHub Flavored Markdown. Raw HTML in reports is left
+// out, as goldmark does by default.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+		// Lower values win, so this replaces the default code block renderer.
+		util.Prioritized(codeBlockRenderer{}, 100),
+	)),
+)
+
+// codeBlockRenderer writes fenced code as usual, except that mermaid blocks
+// become <pre class="mermaid"> for mermaid.js to turn into diagrams.
+type codeBlockRenderer struct{}
+
+func (r codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.render)
+}
+
+func (codeBlockRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	lang := string(n.Language(source))
+
+	open, close := "<pre><code>", "</code></pre>\n"
+	switch {
+	case lang == "mermaid":
+		open, close = `<pre class="mermaid">`, "</pre>\n"
+	case lang != "":
+		open = `<pre><code class="language-` + template.HTMLEscapeString(lang) + `">`
+	}
+
+	w.WriteString(open)
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		w.Write(util.EscapeHTML(seg.Value(source)))
+	}
+	w.WriteString(close)
+	return ast.WalkSkipChildren, nil
+}
+
+// HTML renders a report as a standalone document with print styles. When
+// the report has Mermaid diagrams and mermaidJS is available it is inlined
+// so the file works offline; without it the diagram source is shown.
+func HTML(fm model.ReportFrontMatter, body string, mermaidJS []byte) ([]byte, error) {
+	var rendered bytes.Buffer
+	if err := markdown.Convert([]byte(body), &rendered); err != nil {
+		return nil, err
+	}
+
+	var details []string
+	if p := fm.Period; p != nil && (p.Start != "" || p.End != "") {
+		details = append(details, strings.TrimSpace(p.Start+" – "+p.End))
+	}
+	if fm.Author != "" {
+		details = append(details, fm.Author)
+	}
+	if fm.Status != "" {
+		details = append(details, fm.Status)
+	}
+
+	data := struct {
+		Title   string
+		Details []string
+		Body    template.HTML
+		Mermaid template.JS
+	}{
+		Title:   fm.Title,
+		Details: details,
+		Body:    template.HTML(rendered.String()),
+	}
+	if len(mermaidJS) > 0 && bytes.Contains(rendered.Bytes(), []byte(`<pre class="mermaid">`)) {
+		// A literal </script> inside the bundle would end the element early.
+		data.Mermaid = template.JS(strings.ReplaceAll(string(mermaidJS), "</script", `<\/script`))
+	}
+
+	var out bytes.Buffer
+	if err := htmlPage.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+var htmlPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+  @page { size: A4; margin: 18mm 16mm; }
+  :root { color-scheme: light; }
+  body {
+    margin: 0 auto; padding: 2.5rem 1.5rem; max-width: 46rem;
+    font: 11pt/1.6 -apple-system, BlinkMacSystemFont, "Segoe UI", Inter, Helvetica, Arial, sans-serif;
+    color: #1f2328; background: #fff;
+  }
+  .report-details { margin: 0 0 2rem; color: #59636e; font-size: 0.9em; }
+  h1, h2, h3, h4 { line-height: 1.25; margin: 1.8em 0 0.6em; break-after: avoid; }
+  h1 { font-size: 1.9em; margin-top: 0; padding-bottom: 0.3em; border-bottom: 1px solid #d1d9e0; }
+  h2 { font-size: 1.4em; padding-bottom: 0.2em; border-bottom: 1px solid #eaeef2; }
+  h3 { font-size: 1.15em; }
+  p, ul, ol, table, pre, blockquote { margin: 0 0 1em; }
+  li + li { margin-top: 0.25em; }
+  a { color: #0969da; text-decoration: none; }
+  blockquote { margin-left: 0; padding: 0 1em; color: #59636e; border-left: 0.25em solid #d1d9e0; }
+  code, pre { font-family: "JetBrains Mono", ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 0.88em; }
+  code { padding: 0.15em 0.35em; border-radius: 4px; background: #eff2f5; }
+  pre { padding: 0.9em 1em; overflow-x: auto; border-radius: 6px; background: #f6f8fa; line-height: 1.45; }
+  pre code { padding: 0; background: none; font-size: 1em; }
+  pre.mermaid { background: none; text-align: center; }
+  table { border-collapse: collapse; width: 100%; font-size: 0.95em; }
+  th, td { padding: 0.4em 0.75em; border: 1px solid #d1d9e0; text-align: left; vertical-align: top; }
+  th { background: #f6f8fa; }
+  tr:nth-child(2n) td { background: #fbfcfd; }
+  hr { border: 0; border-top: 1px solid #d1d9e0; margin: 2em 0; }
+  img { max-width: 100%; }
+  input[type="checkbox"] { margin-right: 0.4em; }
+  @media print {
+    body { padding: 0; max-width: none; }
+    a { color: inherit; }
+    a[href^="http"]::after { content: " (" attr(href) ")"; font-size: 0.85em; color: #59636e; word-break: break-all; }
+    pre, table, blockquote, pre.mermaid, tr, img { break-inside: avoid; }
+  }
+</style>
+</head>
+<body>
+{{- with .Details}}
+<p class="report-details">{{range $i, $d := .}}{{if $i}} · {{end}}{{$d}}{{end}}</p>
+{{- end}}
+<main>
+{{.Body}}
+</main>
+{{- if .Mermaid}}
+<script>{{.Mermaid}}</script>
+<script>mermaid.initialize({ startOnLoad: true, theme: "neutral" });</script>
+{{- end}}
+</body>
+</html>
+`))
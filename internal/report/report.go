@@ -0,0 +1,170 @@
+// Package report renders touchpoints through report templates.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// GroupKeys are the ways touchpoints can be grouped in a report.
+var GroupKeys = []string{"category", "tag", "month", "person"}
+
+type Group struct {
+	Name        string
+	Touchpoints []model.Touchpoint
+}
+
+// Data is what a template is executed with. Groups follows GroupBy, and
+// every other grouping is available too for templates that want several.
+// Touchpoints are in date order throughout.
+type Data struct {
+	Title       string
+	Author      string
+	Generated   string
+	StartDate   string
+	EndDate     string
+	GroupBy     string
+	Count       int
+	Touchpoints []model.Touchpoint
+	Groups      []Group
+	ByCategory  []Group
+	ByTag       []Group
+	ByMonth     []Group
+	ByPerson    []Group
+}
+
+// New groups touchpoints for a report. Touchpoints without tags or people
+// are collected under "Untagged" and "Solo".
+func New(req model.ReportRequest, tps []model.Touchpoint) (Data, error) {
+	groupBy := req.GroupBy
+	if groupBy == "" {
+		groupBy = "category"
+	}
+
+	tps = append([]model.Touchpoint(nil), tps...)
+	sort.SliceStable(tps, func(i, j int) bool { return dateOf(tps[i]).Before(dateOf(tps[j])) })
+
+	d := Data{
+		Title:       req.Title,
+		Generated:   time.Now().UTC().Format(time.RFC3339),
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		GroupBy:     groupBy,
+		Count:       len(tps),
+		Touchpoints: tps,
+		ByCategory: groupBySize(tps, func(tp model.Touchpoint) []string {
+			return []string{tp.Category}
+		}),
+		ByTag: groupBySize(tps, func(tp model.Touchpoint) []string {
+			return orDefault(tp.Tags, "Untagged")
+		}),
+		ByPerson: groupBySize(tps, func(tp model.Touchpoint) []string {
+			return orDefault(tp.PeopleInvolved, "Solo")
+		}),
+		ByMonth: groupByMonth(tps),
+	}
+	if d.Title == "" {
+		d.Title = "Touchpoints"
+	}
+
+	switch groupBy {
+	case "category":
+		d.Groups = d.ByCategory
+	case "tag":
+		d.Groups = d.ByTag
+	case "month":
+		d.Groups = d.ByMonth
+	case "person":
+		d.Groups = d.ByPerson
+	default:
+		return Data{}, fmt.Errorf("unknown group_by %q (expected %s)", req.GroupBy, strings.Join(GroupKeys, ", "))
+	}
+	return d, nil
+}
+
+func dateOf(tp model.Touchpoint) time.Time {
+	t, _ := time.Parse(time.RFC3339, tp.Date)
+	return t
+}
+
+func orDefault(values []string, fallback string) []string {
+	if len(values) == 0 {
+		return []string{fallback}
+	}
+	return values
+}
+
+// groupBySize puts each touchpoint in every group keys returns for it, with
+// the largest groups first.
+func groupBySize(tps []model.Touchpoint, keys func(model.Touchpoint) []string) []Group {
+	index := map[string]int{}
+	var groups []Group
+	for _, tp := range tps {
+		for _, k := range keys(tp) {
+			i, ok := index[k]
+			if !ok {
+				i = len(groups)
+				index[k] = i
+				groups = append(groups, Group{Name: k})
+			}
+			groups[i].Touchpoints = append(groups[i].Touchpoints, tp)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Touchpoints) != len(groups[j].Touchpoints) {
+			return len(groups[i].Touchpoints) > len(groups[j].Touchpoints)
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// groupByMonth relies on tps already being in date order.
+func groupByMonth(tps []model.Touchpoint) []Group {
+	var groups []Group
+	for _, tp := range tps {
+		name := dateOf(tp).Format("January 2006")
+		if len(groups) == 0 || groups[len(groups)-1].Name != name {
+			groups = append(groups, Group{Name: name})
+		}
+		last := &groups[len(groups)-1]
+		last.Touchpoints = append(last.Touchpoints, tp)
+	}
+	return groups
+}
+
+var funcs = template.FuncMap{
+	// date formats an RFC3339 timestamp with a Go layout.
+	"date": func(layout, value string) string {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return value
+		}
+		return t.Format(layout)
+	},
+	"join":  func(elems []string, sep string) string { return strings.Join(elems, sep) },
+	"lower": strings.ToLower,
+	// quote renders a string as a double-quoted YAML scalar.
+	"quote": strconv.Quote,
+	"upper": strings.ToUpper,
+}
+
+// Render executes a template's source against d.
+func Render(name, src string, d Data) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tanq16/ohara/internal/auth"
+)
+
+const sessionCookie = "ohara_session"
+
+// identity is who a request acts as.
+type identity struct {
+	// user selects whose data the request reaches; empty is the shared
+	// namespace at the top of the data directory.
+	user string
+	// name is recorded as the author of changes.
+	name  string
+	scope auth.Scope
+}
+
+type identityKey struct{}
+type storeKey struct{}
+
+// requiredScope is the least a token needs for a request. Reads need read,
+// changes need write, and changes that reach beyond a single entry, such as
+// metadata edits, imports that create missing categories and tags, and
+// purging the trash, need admin, as does anything to do with webhooks.
+// Creating a share link needs admin too, as it publishes data to anyone
+// holding the link; revoking one only narrows access, so write will do.
+func requiredScope(r *http.Request) auth.Scope {
+	path := r.URL.Path
+	switch {
+	case path == mcpPath:
+		// MCP only offers the tools a caller's scope allows.
+		return auth.ScopeRead
+	case path == "/api/webhooks", strings.HasPrefix(path, "/api/webhooks/"):
+		// Webhooks send data wherever they point, and their URLs may embed
+		// credentials, so even listing them is for admins.
+		return auth.ScopeAdmin
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return auth.ScopeRead
+	}
+	switch {
+	case strings.HasPrefix(path, "/api/metadata/"),
+		r.Method == http.MethodDelete && (path == "/api/trash" || strings.HasPrefix(path, "/api/trash/")):
+		return auth.ScopeAdmin
+	case path == "/api/import" && createsMetadata(r),
+		r.Method == http.MethodPost && path == "/api/shares":
+		return auth.ScopeAdmin
+	}
+	return auth.ScopeWrite
+}
+
+// createsMetadata reports whether an import asks to add the categories and
+// tags it names, which edits metadata as surely as /api/metadata/ does.
+func createsMetadata(r *http.Request) bool {
+	b, _ := strconv.ParseBool(r.URL.Query().Get("create_missing"))
+	return b
+}
+
+// publicPaths can be reached without signing in.
+var publicPaths = map[string]bool{
+	"/api/login":           true,
+	"/api/logout":          true,
+	"/api/auth/methods":    true,
+	"/api/auth/oidc/login": true,
+	"/api/openapi.json":    true,
+	oidcCallbackPath:       true,
+}
+
+// authEnabled reports whether the API needs credentials, which it does once
+// any token or account exists, or whenever OIDC sign-in is configured.
+func (s *Server) authEnabled() (bool, error) {
+	if s.oidc != nil {
+		return true, nil
+	}
+	if s.config.Tokens != nil {
+		if ok, err := s.config.Tokens.Enabled(); ok || err != nil {
+			return ok, err
+		}
+	}
+	if s.config.Users != nil {
+		return s.config.Users.Enabled()
+	}
+	return false, nil
+}
+
+// authenticate works out who sent a request, from a bearer token or a
+// session cookie. ok is false if neither is present and valid.
+func (s *Server) authenticate(r *http.Request) (id identity, ok bool, err error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		secret, found := strings.CutPrefix(header, "Bearer ")
+		if !found || s.config.Tokens == nil {
+			return identity{}, false, nil
+		}
+		t, err := s.config.Tokens.Verify(strings.TrimSpace(secret))
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return identity{}, false, nil
+		}
+		if err != nil {
+			return identity{}, false, err
+		}
+		id = identity{user: t.User, name: t.Name, scope: t.Scope}
+	} else if c, err := r.Cookie(sessionCookie); err == nil && s.sessions != nil {
+		user, found := s.sessions.Lookup(c.Value)
+		if !found {
+			return identity{}, false, nil
+		}
+		// People have full control over their own data.
+		id = identity{user: user, name: user, scope: auth.ScopeAdmin}
+	} else {
+		return identity{}, false, nil
+	}
+
+	// Accounts can be removed while tokens and sessions are still around.
+	if id.user != "" {
+		if s.config.Users == nil {
+			return identity{}, false, nil
+		}
+		if _, err := s.config.Users.Get(id.user); errors.Is(err, auth.ErrNotFound) {
+			return identity{}, false, nil
+		} else if err != nil {
+			return identity{}, false, err
+		}
+	}
+	return id, true, nil
+}
+
+// withAuth requires a bearer token or a login session on API and MCP
+// requests once any token or account exists, and points the request at the
+// caller's data. The web UI itself stays public; it asks for credentials
+// when the API does.
+func (s *Server) withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api := strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == mcpPath
+		if !api || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		enabled, err := s.authEnabled()
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("Failed to load credentials")
+			writeError(w, http.StatusInternalServerError, "failed to load credentials")
+			return
+		}
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, ok, err := s.authenticate(r)
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("Failed to authenticate request")
+			writeError(w, http.StatusInternalServerError, "failed to authenticate request")
+			return
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ohara"`)
+			writeError(w, http.StatusUnauthorized, "sign in or send a valid API token")
+			return
+		}
+		requestLog(r).UpdateContext(func(c zerolog.Context) zerolog.Context {
+			return c.Str("user", id.name)
+		})
+		if need := requiredScope(r); !id.scope.Allows(need) {
+			writeError(w, http.StatusForbidden, id.name+" lacks "+string(need)+" scope")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), identityKey{}, id)
+		if id.user != "" {
+			st, err := s.stores.get(id.user)
+			if err != nil {
+				requestLog(r).Error().Err(err).Str("user", id.user).Msg("Failed to open user data")
+				writeError(w, http.StatusInternalServerError, "failed to open user data")
+				return
+			}
+			ctx = context.WithValue(ctx, storeKey{}, st)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIdentity returns who a request was authenticated as, if anyone.
+func requestIdentity(r *http.Request) (identity, bool) {
+	id, ok := r.Context().Value(identityKey{}).(identity)
+	return id, ok
+}
+
+// storeFor returns the Storer holding the data a request may reach.
+func (s *Server) storeFor(r *http.Request) Storer {
+	if st, ok := r.Context().Value(storeKey{}).(Storer); ok {
+		return st
+	}
+	return s.store
+}
+
+// storePool opens each user's Storer on first use and keeps it open.
+type storePool struct {
+	open func(user string) (Storer, error)
+
+	mu     sync.Mutex
+	stores map[string]Storer
+}
+
+func (p *storePool) get(user string) (Storer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if st, ok := p.stores[user]; ok {
+		return st, nil
+	}
+	if p.open == nil {
+		return nil, errors.New("per-user storage is not configured")
+	}
+	st, err := p.open(user)
+	if err != nil {
+		return nil, err
+	}
+	if p.stores == nil {
+		p.stores = map[string]Storer{}
+	}
+	p.stores[user] = st
+	return st, nil
+}
+
+// opened returns the Storers opened so far, by user.
+func (p *storePool) opened() map[string]Storer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return maps.Clone(p.stores)
+}
+
+// close closes every Storer that needs it, once the server has stopped.
+func (p *storePool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for user, st := range p.stores {
+		if c, ok := st.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				serverLog().Error().Err(err).Str("user", user).Msg("Failed to close user data")
+			}
+		}
+	}
+	p.stores = nil
+}
+
+// namespaces returns the shared Storer and every account's.
+func (s *Server) namespaces() (map[string]Storer, error) {
+	stores := map[string]Storer{"": s.store}
+	if s.config.Users == nil {
+		return stores, nil
+	}
+	users, err := s.config.Users.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		st, err := s.stores.get(u.Name)
+		if err != nil {
+			return nil, err
+		}
+		stores[u.Name] = st
+	}
+	return stores, nil
+}
+
+type loginPayload struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var p loginPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if s.config.Users == nil {
+		writeError(w, http.StatusUnauthorized, auth.ErrInvalidCredentials.Error())
+		return
+	}
+
+	u, err := s.config.Users.Authenticate(p.Username, p.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		requestLog(r).Info().Str("username", p.Username).Msg("Failed login")
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("Login failed")
+		writeError(w, http.StatusInternalServerError, "login failed")
+		return
+	}
+
+	if err := s.startSession(w, r, u.Name); err != nil {
+		requestLog(r).Error().Err(err).Msg("Failed to create session")
+		writeError(w, http.StatusInternalServerError, "login failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, sessionPayload{Auth: true, User: u.Name, Name: u.Name, Scope: auth.ScopeAdmin})
+}
+
+// startSession signs user in on this browser.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, user string) error {
+	sid, expires, err := s.sessions.Create(user)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    sid,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil && s.sessions != nil {
+		s.sessions.Revoke(c.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type sessionPayload struct {
+	// Auth is false while the API is open to everyone.
+	Auth  bool       `json:"auth"`
+	User  string     `json:"user,omitempty"`
+	Name  string     `json:"name,omitempty"`
+	Scope auth.Scope `json:"scope,omitempty"`
+}
+
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := requestIdentity(r)
+	if !ok {
+		writeJSON(w, http.StatusOK, sessionPayload{})
+		return
+	}
+	writeJSON(w, http.StatusOK, sessionPayload{Auth: true, User: id.user, Name: id.name, Scope: id.scope})
+}
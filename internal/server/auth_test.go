@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tanq16/ohara/internal/auth"
+	"github.com/tanq16/ohara/internal/store"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method, target string
+		want           auth.Scope
+	}{
+		{http.MethodGet, "/api/touchpoints", auth.ScopeRead},
+		{http.MethodHead, "/api/reports", auth.ScopeRead},
+		{http.MethodPost, "/api/touchpoints", auth.ScopeWrite},
+		{http.MethodPut, "/api/touchpoints/t1", auth.ScopeWrite},
+		{http.MethodDelete, "/api/touchpoints/t1", auth.ScopeWrite},
+		{http.MethodPost, "/api/trash/t1/restore", auth.ScopeWrite},
+		{http.MethodDelete, "/api/trash", auth.ScopeAdmin},
+		{http.MethodDelete, "/api/trash/t1", auth.ScopeAdmin},
+		{http.MethodPost, "/api/metadata/categories", auth.ScopeAdmin},
+		{http.MethodGet, "/api/metadata", auth.ScopeRead},
+		{http.MethodPost, "/api/import", auth.ScopeWrite},
+		{http.MethodPost, "/api/import?dry_run=true&create_missing=false", auth.ScopeWrite},
+		{http.MethodPost, "/api/import?create_missing=true", auth.ScopeAdmin},
+		{http.MethodPost, "/api/import?create_missing=1", auth.ScopeAdmin},
+		{http.MethodGet, "/api/shares", auth.ScopeRead},
+		{http.MethodPost, "/api/shares", auth.ScopeAdmin},
+		{http.MethodDelete, "/api/shares/s1", auth.ScopeWrite},
+		{http.MethodGet, "/api/webhooks", auth.ScopeAdmin},
+		{http.MethodPost, "/api/webhooks/w1/test", auth.ScopeAdmin},
+		{http.MethodPost, mcpPath, auth.ScopeRead},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		if got := requiredScope(r); got != tt.want {
+			t.Errorf("%s %s: scope %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestSessionCookieSecure(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		proto     string
+		want      bool
+	}{
+		{"plain http", "", "", false},
+		{"forwarded https", "", "https", true},
+		{"public https", "https://ohara.example.com", "", true},
+		// PublicURL is trusted over the header, which anyone can send.
+		{"public http", "http://ohara.example.com", "https", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := auth.NewUserStore(t.TempDir())
+			if _, err := users.Add("ada", "correct horse"); err != nil {
+				t.Fatal(err)
+			}
+			st, err := store.New(store.Config{DataDir: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := New(Config{Users: users, PublicURL: tt.publicURL, SessionTTL: time.Hour}, st)
+
+			for _, path := range []string{"/api/login", "/api/logout"} {
+				r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"username": "ada", "password": "correct horse"}`))
+				if tt.proto != "" {
+					r.Header.Set("X-Forwarded-Proto", tt.proto)
+				}
+				rec := httptest.NewRecorder()
+				s.mux.ServeHTTP(rec, r)
+				cookies := rec.Result().Cookies()
+				if len(cookies) != 1 {
+					t.Fatalf("%s: status %d, cookies %v", path, rec.Code, cookies)
+				}
+				if cookies[0].Secure != tt.want {
+					t.Errorf("%s: Secure %v, want %v", path, cookies[0].Secure, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+	"github.com/tanq16/ohara/internal/webhook"
+)
+
+const (
+	// eventBacklog is how many recent events are kept for streams resuming
+	// with Last-Event-ID.
+	eventBacklog = 1024
+	// eventBuffer is how many events a stream may fall behind by before it
+	// is cut off to resume from the backlog.
+	eventBuffer = 64
+	// resetEvent tells a stream's client that events were missed, so it
+	// should reload everything.
+	resetEvent = "reset"
+
+	sseRetry     = 3 * time.Second
+	sseKeepAlive = 25 * time.Second
+)
+
+// Events carries changes from the stores to event streams and webhooks.
+// Stores announce their changes through Hook, so every change is seen
+// however it was made, as long as it was made in this process.
+type Events struct {
+	bus      *eventBus
+	webhooks *webhook.Dispatcher
+}
+
+// NewEvents returns Events delivering to webhooks as well as event streams;
+// webhooks may be nil.
+func NewEvents(webhooks *webhook.Dispatcher) *Events {
+	return &Events{bus: newEventBus(), webhooks: webhooks}
+}
+
+// Hook returns the store.Config.OnChange function for owner's namespace.
+func (e *Events) Hook(owner string) func(typ string, data any) {
+	return func(typ string, data any) {
+		e.publish(owner, typ, data)
+	}
+}
+
+// Close ends every event stream. Run does this when the server shuts down.
+func (e *Events) Close() {
+	e.bus.close()
+}
+
+// publish announces a change to owner's data to their event streams and
+// webhooks.
+func (e *Events) publish(owner, typ string, data any) {
+	ev := e.bus.publish(owner, typ, data)
+	if e.webhooks == nil {
+		return
+	}
+	if err := e.webhooks.Publish(owner, ev); err != nil {
+		serverLog().Error().Err(err).Str("event", typ).Msg("Failed to queue webhook deliveries")
+	}
+}
+
+// eventBus numbers events and hands them to the streams of the namespace
+// they belong to, keeping the latest so reconnecting clients miss nothing.
+// IDs are <epoch>-<seq>, with an epoch that changes on every start, so IDs
+// from before a restart are known not to resume.
+type eventBus struct {
+	epoch string
+
+	mu      sync.Mutex
+	seq     uint64
+	backlog []busEvent
+	subs    map[*eventSub]bool
+	closed  bool
+}
+
+type busEvent struct {
+	model.Event
+	owner string
+	seq   uint64
+}
+
+type eventSub struct {
+	owner string
+	ch    chan model.Event
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		epoch: strconv.FormatInt(time.Now().UnixMilli(), 36),
+		subs:  map[*eventSub]bool{},
+	}
+}
+
+func (b *eventBus) id(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func (b *eventBus) publish(owner, typ string, data any) model.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	ev := model.Event{
+		ID:        b.id(b.seq),
+		Type:      typ,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Data:      data,
+	}
+	b.backlog = append(b.backlog, busEvent{Event: ev, owner: owner, seq: b.seq})
+	if n := len(b.backlog) - eventBacklog; n > 0 {
+		b.backlog = b.backlog[n:]
+	}
+	for sub := range b.subs {
+		if sub.owner != owner {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			// Too slow to keep up; its client reconnects and resumes from
+			// the backlog.
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return ev
+}
+
+// subscribe starts a stream of owner's events. Given the ID of the last
+// event a client saw, it also returns the ones since, or a reset event if
+// they are no longer all in the backlog.
+func (b *eventBus) subscribe(owner, lastID string) (*eventSub, []model.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := &eventSub{owner: owner, ch: make(chan model.Event, eventBuffer)}
+	if b.closed {
+		close(sub.ch)
+	} else {
+		b.subs[sub] = true
+	}
+	if lastID == "" {
+		return sub, nil
+	}
+
+	epoch, seqStr, _ := strings.Cut(lastID, "-")
+	last, err := strconv.ParseUint(seqStr, 10, 64)
+	resumable := err == nil && epoch == b.epoch && last <= b.seq &&
+		(len(b.backlog) == 0 || b.backlog[0].seq <= last+1)
+	if !resumable {
+		return sub, []model.Event{{
+			ID:        b.id(b.seq),
+			Type:      resetEvent,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		}}
+	}
+	var missed []model.Event
+	for _, ev := range b.backlog {
+		if ev.seq > last && ev.owner == owner {
+			missed = append(missed, ev.Event)
+		}
+	}
+	return sub, missed
+}
+
+func (b *eventBus) unsubscribe(sub *eventSub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[sub] {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// close ends every stream, so shutdown need not wait for clients to leave.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.ch)
+	}
+	clear(b.subs)
+}
+
+// streamEvents sends the changes to the caller's data as Server-Sent
+// Events until the client goes away or the server stops. Clients resume
+// with the Last-Event-ID header, or the last_event_id parameter where they
+// cannot set headers.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	lastID := cmp.Or(r.Header.Get("Last-Event-ID"), r.URL.Query().Get("last_event_id"))
+	sub, missed := s.events.bus.subscribe(requestOwner(r), lastID)
+	defer s.events.bus.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	// Streams stay open far beyond the server's write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		requestLog(r).Error().Err(err).Msg("Failed to lift write deadline for event stream")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	for _, ev := range missed {
+		writeEvent(w, ev)
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case ev, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, ev)
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes ev as an SSE message named after its type, with the
+// whole event as JSON data.
+func writeEvent(w io.Writer, ev model.Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		serverLog().Error().Err(err).Str("event", ev.Type).Msg("Failed to encode event")
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tanq16/ohara/internal/exporter"
+)
+
+// flushWriter pushes each write to the client so long exports stream, and
+// remembers whether anything has been sent.
+type flushWriter struct {
+	w     http.ResponseWriter
+	wrote bool
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	fw.wrote = true
+	n, err := fw.w.Write(p)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+func (s *Server) exportTouchpoints(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	format, err := exporter.ParseFormat(q.Get("format"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := filterFromQuery(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="touchpoints.`+string(format)+`"`)
+
+	// Large exports can outlast the server's write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		requestLog(r).Error().Err(err).Msg("Failed to lift write deadline for export")
+	}
+
+	fw := &flushWriter{w: w}
+	if err := exporter.Export(fw, format, filter, s.storeFor(r).ListTouchpoints); err != nil {
+		if !fw.wrote {
+			w.Header().Del("Content-Disposition")
+			writeStoreError(w, r, err)
+			return
+		}
+		// Headers are gone; all that is left is to cut the response short.
+		requestLog(r).Error().Err(err).Msg("Export failed mid-stream")
+		panic(http.ErrAbortHandler)
+	}
+}
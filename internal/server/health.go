@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+)
+
+// healthz reports that the process is up and serving.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether requests can be served: the data directories must
+// be writable and their files must parse, as must the credential files.
+// Accounts' data is only checked once something has opened it. Anyone may
+// ask, so only the outcome is returned; what failed is logged.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			requestLog(r).Warn().Err(err).Str("check", name).Msg("Readiness check failed")
+			ready = false
+		}
+	}
+
+	check("store", s.store.Check())
+	for user, st := range s.stores.opened() {
+		check("store:"+user, st.Check())
+	}
+	_, err := s.authEnabled()
+	check("credentials", err)
+
+	if !ready {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/tanq16/ohara/internal/importer"
+	"github.com/tanq16/ohara/internal/model"
+)
+
+const maxImportSize = 10 << 20
+
+// importTouchpoints takes the file as the request body. The format comes
+// from the format parameter or, failing that, the Content-Type.
+func (s *Server) importTouchpoints(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var format importer.Format
+	if name := q.Get("format"); name != "" {
+		f, err := importer.ParseFormat(name)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format = f
+	} else if f, ok := importer.FormatFromContentType(r.Header.Get("Content-Type")); ok {
+		format = f
+	} else {
+		writeError(w, http.StatusBadRequest, "format is required (csv, json or ndjson)")
+		return
+	}
+
+	mapping, err := importer.ParseMapping(q["map"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var opts model.ImportOptions
+	for name, dst := range map[string]*bool{"dry_run": &opts.DryRun, "create_missing": &opts.CreateMissing} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+name+": "+v)
+				return
+			}
+			*dst = b
+		}
+	}
+
+	rows, err := importer.Parse(http.MaxBytesReader(w, r.Body, maxImportSize), format, mapping)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "import file is too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.storeFor(r).ImportTouchpoints(rows, opts, requestAuthor(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
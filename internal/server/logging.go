@@ -0,0 +1,140 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	stdlog "log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits which incoming request IDs are kept, so a proxy's
+// ID carries through but arbitrary header content never reaches the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// probePaths are polled by monitoring; successful hits are only logged at
+// debug level so they do not drown out everything else.
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// serverLog is the logger for events that are not tied to a request.
+func serverLog() *zerolog.Logger {
+	l := log.With().Str("package", "server").Logger()
+	return &l
+}
+
+// requestLog is the logger for a request, tagged with its ID.
+func requestLog(r *http.Request) *zerolog.Logger {
+	return zerolog.Ctx(r.Context())
+}
+
+// httpErrorLog sends net/http's own errors, such as failed TLS handshakes,
+// to the server log.
+func httpErrorLog() *stdlog.Logger {
+	return stdlog.New(errorLogWriter{}, "", 0)
+}
+
+type errorLogWriter struct{}
+
+func (errorLogWriter) Write(p []byte) (int, error) {
+	serverLog().Warn().Msg(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+// accessRecorder remembers the status and size of a response for the access
+// log and metrics.
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (a *accessRecorder) WriteHeader(code int) {
+	if a.status == 0 {
+		a.status = code
+	}
+	a.ResponseWriter.WriteHeader(code)
+}
+
+func (a *accessRecorder) Write(p []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(p)
+	a.bytes += int64(n)
+	return n, err
+}
+
+func (a *accessRecorder) Flush() {
+	if f, ok := a.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection underneath.
+func (a *accessRecorder) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withLogging gives each request an ID, echoed in the X-Request-ID header
+// and attached to everything logged while serving it, and writes an access
+// log line once the response is done.
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		r = r.WithContext(log.With().Str("package", "server").Str("request_id", id).Logger().WithContext(r.Context()))
+		// Later middleware may add fields, such as the user, to this logger.
+		logger := zerolog.Ctx(r.Context())
+		rec := &accessRecorder{ResponseWriter: w}
+
+		aborted := true
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := zerolog.InfoLevel
+			switch {
+			case aborted:
+				level = zerolog.WarnLevel
+			case status >= 500:
+				level = zerolog.ErrorLevel
+			case status < 400 && probePaths[r.URL.Path]:
+				level = zerolog.DebugLevel
+			}
+			logger.WithLevel(level).
+				Bool("aborted", aborted).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Int("status", status).
+				Int64("bytes", rec.bytes).
+				Dur("duration_ms", time.Since(start)).
+				Str("remote", r.RemoteAddr).
+				Msg("Request")
+		}()
+		next.ServeHTTP(rec, r)
+		aborted = false
+	})
+}
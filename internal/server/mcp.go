@@ -0,0 +1,248 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/tanq16/ohara/internal/auth"
+	"github.com/tanq16/ohara/internal/model"
+	"github.com/tanq16/ohara/internal/store"
+)
+
+const (
+	mcpPath           = "/mcp"
+	reportURIPrefix   = "ohara://reports/"
+	defaultMCPPageLen = 50
+)
+
+const mcpInstructions = `Ohara tracks professional touchpoints: achievements, glue work and feedback,
+each with a date, description, category, tags, people involved and a URL.
+Call list_metadata before create_touchpoint, since categories and tags must
+be ones that already exist. Reports are Markdown documents; list_reports
+finds them and each is readable as an ohara://reports/<filename> resource.`
+
+// mcpSchemas caches the tool schemas inferred from the argument types, which
+// would otherwise be worked out again for every HTTP request.
+var mcpSchemas = mcp.NewSchemaCache()
+
+// MCPOptions configure a Model Context Protocol server.
+type MCPOptions struct {
+	// Version is reported to clients.
+	Version string
+	// Author is recorded in the history of touchpoints the tools change.
+	Author string
+	// ReadOnly leaves out the tools that change data.
+	ReadOnly bool
+}
+
+// NewMCP returns a Model Context Protocol server with tools and resources
+// over st, for AI assistants to log and look up touchpoints. Reports are
+// served through a resource template, so building a server reads nothing
+// from st.
+func NewMCP(st Storer, opts MCPOptions) *mcp.Server {
+	srv := mcp.NewServer(&mcp.Implementation{Name: "ohara", Version: opts.Version}, &mcp.ServerOptions{
+		Instructions: mcpInstructions,
+		SchemaCache:  mcpSchemas,
+		HasResources: true,
+	})
+	t := mcpTools{st: st, author: cmp.Or(opts.Author, "mcp")}
+
+	mcp.AddTool(srv, &mcp.Tool{
+		Name:        "search_touchpoints",
+		Description: "Find touchpoints by full-text search, a field query, categories, tags and dates. Results are paged; pass next_cursor back as cursor for more.",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, t.search)
+	mcp.AddTool(srv, &mcp.Tool{
+		Name:        "list_metadata",
+		Description: "List the categories and tags touchpoints may use.",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, t.metadata)
+	mcp.AddTool(srv, &mcp.Tool{
+		Name:        "list_reports",
+		Description: "List saved reports with their front matter, optionally by status and period. Read one as the resource in its uri.",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true},
+	}, t.reports)
+	if !opts.ReadOnly {
+		mcp.AddTool(srv, &mcp.Tool{
+			Name:        "create_touchpoint",
+			Description: "Log a new touchpoint. The category and tags must come from list_metadata.",
+		}, t.create)
+		mcp.AddTool(srv, &mcp.Tool{
+			Name:        "generate_report",
+			Description: "Render a report template over the matching touchpoints and save it as a new Markdown report.",
+		}, t.generate)
+	}
+
+	srv.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "report",
+		Description: "A Markdown report, by filename.",
+		MIMEType:    "text/markdown",
+		URITemplate: reportURIPrefix + "{filename}",
+	}, t.readReport)
+	return srv
+}
+
+type mcpTools struct {
+	st     Storer
+	author string
+}
+
+func (t mcpTools) readReport(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+	filename, ok := strings.CutPrefix(uri, reportURIPrefix)
+	if !ok {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	content, err := t.st.GetReport(filename)
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrInvalidFilename) {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+		{URI: uri, MIMEType: "text/markdown", Text: content},
+	}}, nil
+}
+
+type listReportsArgs struct {
+	Statuses  []string `json:"statuses,omitempty" jsonschema:"only reports with these statuses, such as draft or final"`
+	StartDate string   `json:"start_date,omitempty" jsonschema:"only reports whose period ends on or after this date, as RFC3339 or YYYY-MM-DD"`
+	EndDate   string   `json:"end_date,omitempty" jsonschema:"only reports whose period starts on or before this date, as RFC3339 or YYYY-MM-DD"`
+}
+
+type mcpReport struct {
+	model.ReportInfo
+	URI string `json:"uri"`
+}
+
+type listReportsResult struct {
+	Reports []mcpReport `json:"reports"`
+}
+
+func (t mcpTools) reports(ctx context.Context, req *mcp.CallToolRequest, args listReportsArgs) (*mcp.CallToolResult, listReportsResult, error) {
+	infos, err := t.st.ListReports(model.ReportFilter{
+		Statuses:  args.Statuses,
+		StartDate: args.StartDate,
+		EndDate:   args.EndDate,
+	})
+	if err != nil {
+		return nil, listReportsResult{}, err
+	}
+	res := listReportsResult{Reports: make([]mcpReport, len(infos))}
+	for i, info := range infos {
+		res.Reports[i] = mcpReport{ReportInfo: info, URI: reportURIPrefix + info.Filename}
+	}
+	return nil, res, nil
+}
+
+type searchArgs struct {
+	Q          string   `json:"q,omitempty" jsonschema:"full-text search over description, people and URL; all words must match, \"quoted phrases\" match in order and word* matches prefixes"`
+	Query      string   `json:"query,omitempty" jsonschema:"boolean expression over category, tag, person, text, url and date fields, e.g. category:\"Bug Fix\" AND (tag:backend OR tag:security) AND date>=2025-01-01"`
+	Categories []string `json:"categories,omitempty" jsonschema:"match any of these categories"`
+	Tags       []string `json:"tags,omitempty" jsonschema:"match any of these tags"`
+	StartDate  string   `json:"start_date,omitempty" jsonschema:"earliest date, as RFC3339 or YYYY-MM-DD"`
+	EndDate    string   `json:"end_date,omitempty" jsonschema:"latest date, as RFC3339 or YYYY-MM-DD"`
+	Sort       string   `json:"sort,omitempty" jsonschema:"date or category; by default results come in creation order, or by relevance with q"`
+	Order      string   `json:"order,omitempty" jsonschema:"asc or desc"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"page size, 50 by default"`
+	Cursor     string   `json:"cursor,omitempty" jsonschema:"next_cursor from the previous page"`
+}
+
+func (t mcpTools) search(ctx context.Context, req *mcp.CallToolRequest, args searchArgs) (*mcp.CallToolResult, model.TouchpointPage, error) {
+	page, err := t.st.ListTouchpoints(model.TouchpointFilter{
+		Categories: args.Categories,
+		Tags:       args.Tags,
+		StartDate:  args.StartDate,
+		EndDate:    args.EndDate,
+		Search:     args.Q,
+		Query:      args.Query,
+		Sort:       args.Sort,
+		Order:      args.Order,
+		Limit:      cmp.Or(max(args.Limit, 0), defaultMCPPageLen),
+		Cursor:     args.Cursor,
+	})
+	return nil, page, err
+}
+
+func (t mcpTools) metadata(ctx context.Context, req *mcp.CallToolRequest, args struct{}) (*mcp.CallToolResult, model.Metadata, error) {
+	md, err := t.st.GetMetadata()
+	return nil, md, err
+}
+
+type touchpointArgs struct {
+	Description    string   `json:"description" jsonschema:"what happened, in a sentence or two"`
+	Category       string   `json:"category" jsonschema:"one of the categories from list_metadata"`
+	Tags           []string `json:"tags,omitempty" jsonschema:"tags from list_metadata"`
+	PeopleInvolved []string `json:"people_involved,omitempty" jsonschema:"names of the people involved"`
+	URL            string   `json:"url,omitempty" jsonschema:"a link to the work, such as a pull request or document"`
+	Date           string   `json:"date,omitempty" jsonschema:"when it happened, as RFC3339 or YYYY-MM-DD; defaults to now"`
+}
+
+func (t mcpTools) create(ctx context.Context, req *mcp.CallToolRequest, args touchpointArgs) (*mcp.CallToolResult, model.Touchpoint, error) {
+	tp, err := t.st.CreateTouchpoint(model.TouchpointInput{
+		Date:           args.Date,
+		Description:    args.Description,
+		Category:       args.Category,
+		Tags:           args.Tags,
+		PeopleInvolved: args.PeopleInvolved,
+		URL:            args.URL,
+	}, t.author)
+	return nil, tp, err
+}
+
+type reportArgs struct {
+	Template   string   `json:"template,omitempty" jsonschema:"template name, brag by default"`
+	Title      string   `json:"title,omitempty" jsonschema:"report title"`
+	GroupBy    string   `json:"group_by,omitempty" jsonschema:"category, tag, month or person"`
+	Filename   string   `json:"filename,omitempty" jsonschema:"name ending in .md; defaults to <template>-<timestamp>.md"`
+	StartDate  string   `json:"start_date,omitempty" jsonschema:"earliest touchpoint date, as RFC3339 or YYYY-MM-DD"`
+	EndDate    string   `json:"end_date,omitempty" jsonschema:"latest touchpoint date, as RFC3339 or YYYY-MM-DD"`
+	Categories []string `json:"categories,omitempty" jsonschema:"only touchpoints in these categories"`
+	Tags       []string `json:"tags,omitempty" jsonschema:"only touchpoints with these tags"`
+	Q          string   `json:"q,omitempty" jsonschema:"full-text search the touchpoints must match"`
+	Query      string   `json:"query,omitempty" jsonschema:"field query the touchpoints must match"`
+}
+
+type reportResult struct {
+	Filename string `json:"filename"`
+	URI      string `json:"uri"`
+	Content  string `json:"content"`
+}
+
+func (t mcpTools) generate(ctx context.Context, req *mcp.CallToolRequest, args reportArgs) (*mcp.CallToolResult, reportResult, error) {
+	filename, content, err := generateReport(t.st, model.ReportRequest{
+		Template:   args.Template,
+		Filename:   args.Filename,
+		Title:      args.Title,
+		GroupBy:    args.GroupBy,
+		StartDate:  args.StartDate,
+		EndDate:    args.EndDate,
+		Categories: args.Categories,
+		Tags:       args.Tags,
+		Search:     args.Q,
+		Query:      args.Query,
+	}, t.author)
+	if err != nil {
+		return nil, reportResult{}, err
+	}
+	return nil, reportResult{Filename: filename, URI: reportURIPrefix + filename, Content: content}, nil
+}
+
+// mcpHandler serves MCP over streamable HTTP. It runs statelessly, building
+// a server for each request from that request's credentials, so sessions
+// never outlive the tokens or logins behind them.
+func (s *Server) mcpHandler() http.Handler {
+	return mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server {
+		opts := MCPOptions{Version: s.config.Version, Author: requestAuthor(r)}
+		if id, ok := requestIdentity(r); ok {
+			opts.ReadOnly = !id.scope.Allows(auth.ScopeWrite)
+		}
+		return NewMCP(s.storeFor(r), opts)
+	}, &mcp.StreamableHTTPOptions{Stateless: true, JSONResponse: true})
+}
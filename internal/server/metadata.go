@@ -6,9 +6,9 @@ import (
 )
 
 func (s *Server) getMetadata(w http.ResponseWriter, r *http.Request) {
-	md, err := s.store.GetMetadata()
+	md, err := s.storeFor(r).GetMetadata()
 	if err != nil {
-		writeStoreError(w, err)
+		writeStoreError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, md)
@@ -25,8 +25,8 @@ func (s *Server) addCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.store.AddCategory(p.Name); err != nil {
-		writeStoreError(w, err)
+	if err := s.storeFor(r).AddCategory(p.Name); err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
 
@@ -35,8 +35,8 @@ func (s *Server) addCategory(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) removeCategory(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
-	if err := s.store.RemoveCategory(name); err != nil {
-		writeStoreError(w, err)
+	if err := s.storeFor(r).RemoveCategory(name); err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -49,8 +49,8 @@ func (s *Server) addTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.store.AddTag(p.Name); err != nil {
-		writeStoreError(w, err)
+	if err := s.storeFor(r).AddTag(p.Name); err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
 
@@ -59,8 +59,8 @@ func (s *Server) addTag(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) removeTag(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
-	if err := s.store.RemoveTag(name); err != nil {
-		writeStoreError(w, err)
+	if err := s.storeFor(r).RemoveTag(name); err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// metrics holds the collectors served at /metrics, on a registry of the
+// server's own.
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	storeDuration   *prometheus.HistogramVec
+}
+
+func newMetrics(s *Server) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ohara_http_requests_total",
+			Help: "HTTP requests served, by route pattern and status code.",
+		}, []string{"route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ohara_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ohara_store_operation_duration_seconds",
+			Help:    "Time taken by storage operations, by operation.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"operation"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.storeDuration,
+		dataCollector{s},
+	)
+	return m
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// withMetrics counts and times requests by the pattern they matched in
+// setup, so paths with IDs in them do not each get their own series.
+func (s *Server) withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := s.mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		rec := &accessRecorder{ResponseWriter: w}
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			s.metrics.requests.WithLabelValues(route, strconv.Itoa(status)).Inc()
+			s.metrics.requestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+var (
+	touchpointsDesc = prometheus.NewDesc("ohara_touchpoints", "Touchpoints in open namespaces, not counting the trash.", nil, nil)
+	trashDesc       = prometheus.NewDesc("ohara_trashed_touchpoints", "Touchpoints in the trash of open namespaces.", nil, nil)
+	reportsDesc     = prometheus.NewDesc("ohara_reports", "Reports in open namespaces.", nil, nil)
+)
+
+// dataCollector counts touchpoints and reports when scraped. Totals are
+// summed over namespaces so account names never appear in metrics. Only the
+// shared namespace and accounts something has already opened are counted,
+// so a scrape never opens every account's data.
+type dataCollector struct{ s *Server }
+
+func (c dataCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- touchpointsDesc
+	ch <- trashDesc
+	ch <- reportsDesc
+}
+
+func (c dataCollector) Collect(ch chan<- prometheus.Metric) {
+	stores := []Storer{c.s.store}
+	for _, st := range c.s.stores.opened() {
+		stores = append(stores, st)
+	}
+	var total model.Counts
+	for _, st := range stores {
+		c, err := st.Counts()
+		if err != nil {
+			for _, desc := range []*prometheus.Desc{touchpointsDesc, trashDesc, reportsDesc} {
+				ch <- prometheus.NewInvalidMetric(desc, err)
+			}
+			return
+		}
+		total.Touchpoints += c.Touchpoints
+		total.Trashed += c.Trashed
+		total.Reports += c.Reports
+	}
+	ch <- prometheus.MustNewConstMetric(touchpointsDesc, prometheus.GaugeValue, float64(total.Touchpoints))
+	ch <- prometheus.MustNewConstMetric(trashDesc, prometheus.GaugeValue, float64(total.Trashed))
+	ch <- prometheus.MustNewConstMetric(reportsDesc, prometheus.GaugeValue, float64(total.Reports))
+}
+
+// timedStore records how long each operation on a Storer takes.
+type timedStore struct {
+	Storer
+	duration *prometheus.HistogramVec
+}
+
+func (m *metrics) instrument(st Storer) Storer {
+	return timedStore{Storer: st, duration: m.storeDuration}
+}
+
+func (t timedStore) observe(op string, start time.Time) {
+	t.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// Close closes the underlying Storer if it needs closing.
+func (t timedStore) Close() error {
+	if c, ok := t.Storer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (t timedStore) ListTouchpoints(f model.TouchpointFilter) (model.TouchpointPage, error) {
+	defer t.observe("ListTouchpoints", time.Now())
+	return t.Storer.ListTouchpoints(f)
+}
+
+func (t timedStore) CreateTouchpoint(input model.TouchpointInput, author string) (model.Touchpoint, error) {
+	defer t.observe("CreateTouchpoint", time.Now())
+	return t.Storer.CreateTouchpoint(input, author)
+}
+
+func (t timedStore) UpdateTouchpoint(id string, input model.TouchpointInput, author string) (model.Touchpoint, error) {
+	defer t.observe("UpdateTouchpoint", time.Now())
+	return t.Storer.UpdateTouchpoint(id, input, author)
+}
+
+func (t timedStore) DeleteTouchpoint(id, author string) error {
+	defer t.observe("DeleteTouchpoint", time.Now())
+	return t.Storer.DeleteTouchpoint(id, author)
+}
+
+func (t timedStore) TouchpointHistory(id string) ([]model.Revision, error) {
+	defer t.observe("TouchpointHistory", time.Now())
+	return t.Storer.TouchpointHistory(id)
+}
+
+func (t timedStore) RestoreRevision(id string, rev int, author string) (model.Touchpoint, error) {
+	defer t.observe("RestoreRevision", time.Now())
+	return t.Storer.RestoreRevision(id, rev, author)
+}
+
+func (t timedStore) ListTrash() ([]model.Touchpoint, error) {
+	defer t.observe("ListTrash", time.Now())
+	return t.Storer.ListTrash()
+}
+
+func (t timedStore) RestoreTouchpoint(id, author string) (model.Touchpoint, error) {
+	defer t.observe("RestoreTouchpoint", time.Now())
+	return t.Storer.RestoreTouchpoint(id, author)
+}
+
+func (t timedStore) PurgeTouchpoint(id string) error {
+	defer t.observe("PurgeTouchpoint", time.Now())
+	return t.Storer.PurgeTouchpoint(id)
+}
+
+func (t timedStore) PurgeTrash(before time.Time) (int, error) {
+	defer t.observe("PurgeTrash", time.Now())
+	return t.Storer.PurgeTrash(before)
+}
+
+func (t timedStore) ImportTouchpoints(rows []model.ImportRow, opts model.ImportOptions, author string) (model.ImportReport, error) {
+	defer t.observe("ImportTouchpoints", time.Now())
+	return t.Storer.ImportTouchpoints(rows, opts, author)
+}
+
+func (t timedStore) GetMetadata() (model.Metadata, error) {
+	defer t.observe("GetMetadata", time.Now())
+	return t.Storer.GetMetadata()
+}
+
+func (t timedStore) AddCategory(name string) error {
+	defer t.observe("AddCategory", time.Now())
+	return t.Storer.AddCategory(name)
+}
+
+func (t timedStore) RemoveCategory(name string) error {
+	defer t.observe("RemoveCategory", time.Now())
+	return t.Storer.RemoveCategory(name)
+}
+
+func (t timedStore) AddTag(name string) error {
+	defer t.observe("AddTag", time.Now())
+	return t.Storer.AddTag(name)
+}
+
+func (t timedStore) RemoveTag(name string) error {
+	defer t.observe("RemoveTag", time.Now())
+	return t.Storer.RemoveTag(name)
+}
+
+func (t timedStore) ListReports(f model.ReportFilter) ([]model.ReportInfo, error) {
+	defer t.observe("ListReports", time.Now())
+	return t.Storer.ListReports(f)
+}
+
+func (t timedStore) GetReport(filename string) (string, error) {
+	defer t.observe("GetReport", time.Now())
+	return t.Storer.GetReport(filename)
+}
+
+func (t timedStore) CreateReport(filename, content string) error {
+	defer t.observe("CreateReport", time.Now())
+	return t.Storer.CreateReport(filename, content)
+}
+
+func (t timedStore) UpdateReport(filename, content, version string) error {
+	defer t.observe("UpdateReport", time.Now())
+	return t.Storer.UpdateReport(filename, content, version)
+}
+
+func (t timedStore) RenameReport(filename, newFilename string) error {
+	defer t.observe("RenameReport", time.Now())
+	return t.Storer.RenameReport(filename, newFilename)
+}
+
+func (t timedStore) DeleteReport(filename string) error {
+	defer t.observe("DeleteReport", time.Now())
+	return t.Storer.DeleteReport(filename)
+}
+
+func (t timedStore) ListTemplates() ([]string, error) {
+	defer t.observe("ListTemplates", time.Now())
+	return t.Storer.ListTemplates()
+}
+
+func (t timedStore) GetTemplate(name string) (string, error) {
+	defer t.observe("GetTemplate", time.Now())
+	return t.Storer.GetTemplate(name)
+}
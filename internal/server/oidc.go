@@ -0,0 +1,298 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateCookie  = "ohara_oidc_state"
+	oidcCallbackPath = "/api/auth/oidc/callback"
+	// oidcLoginTimeout is how long someone has to finish signing in at the
+	// provider.
+	oidcLoginTimeout = 10 * time.Minute
+)
+
+// OIDCConfig enables signing in through an OpenID Connect provider.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered with the provider; by default
+	// it is derived from the server's public URL.
+	RedirectURL string
+	// AllowedDomains limits sign-in to verified emails in these domains;
+	// empty admits anyone the provider vouches for.
+	AllowedDomains []string
+	// HTTPClient talks to the provider; nil uses http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// oidcLogin runs the authorization code flow with PKCE. The provider is
+// discovered on first use, so the server starts even while it is down.
+type oidcLogin struct {
+	cfg OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	pending  map[string]oidcPending
+}
+
+// oidcPending is a sign-in started but not yet completed, keyed by state.
+type oidcPending struct {
+	verifier    string
+	nonce       string
+	redirectURL string
+	expires     time.Time
+}
+
+func (o *oidcLogin) context(ctx context.Context) context.Context {
+	if o.cfg.HTTPClient != nil {
+		return oidc.ClientContext(ctx, o.cfg.HTTPClient)
+	}
+	return ctx
+}
+
+func (o *oidcLogin) discover(ctx context.Context) (*oidc.Provider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.provider != nil {
+		return o.provider, nil
+	}
+	p, err := oidc.NewProvider(o.context(ctx), o.cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	o.provider = p
+	return p, nil
+}
+
+func (o *oidcLogin) oauth2Config(p *oidc.Provider, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     o.cfg.ClientID,
+		ClientSecret: o.cfg.ClientSecret,
+		Endpoint:     p.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+}
+
+func (o *oidcLogin) redirectURL(origin string) string {
+	if o.cfg.RedirectURL != "" {
+		return o.cfg.RedirectURL
+	}
+	return origin + oidcCallbackPath
+}
+
+func (o *oidcLogin) begin(state string, p oidcPending) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	for k, v := range o.pending {
+		if !now.Before(v.expires) {
+			delete(o.pending, k)
+		}
+	}
+	if o.pending == nil {
+		o.pending = map[string]oidcPending{}
+	}
+	o.pending[state] = p
+}
+
+// take returns and forgets a pending sign-in, so each state is used once.
+func (o *oidcLogin) take(state string) (oidcPending, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	p, ok := o.pending[state]
+	delete(o.pending, state)
+	if !ok || !time.Now().Before(p.expires) {
+		return oidcPending{}, false
+	}
+	return p, true
+}
+
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+// checkDomain enforces AllowedDomains, which only admits addresses the
+// provider says are verified.
+func (o *oidcLogin) checkDomain(c oidcClaims) error {
+	if len(o.cfg.AllowedDomains) == 0 {
+		return nil
+	}
+	_, domain, ok := strings.Cut(c.Email, "@")
+	if !ok {
+		return errors.New("the identity provider did not share an email address")
+	}
+	if c.EmailVerified == nil || !*c.EmailVerified {
+		return fmt.Errorf("email %s is not verified", c.Email)
+	}
+	if !slices.ContainsFunc(o.cfg.AllowedDomains, func(d string) bool { return strings.EqualFold(d, domain) }) {
+		return fmt.Errorf("email domain %s is not allowed", domain)
+	}
+	return nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// oidcStart sends the browser to the provider to sign in.
+func (s *Server) oidcStart(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	provider, err := s.oidc.discover(r.Context())
+	if err != nil {
+		requestLog(r).Error().Err(err).Str("issuer", s.oidc.cfg.Issuer).Msg("OIDC discovery failed")
+		http.Error(w, "identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state, err := randomString(24)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomString(24)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	p := oidcPending{
+		verifier:    oauth2.GenerateVerifier(),
+		nonce:       nonce,
+		redirectURL: s.oidc.redirectURL(s.origin(r)),
+		expires:     time.Now().Add(oidcLoginTimeout),
+	}
+	s.oidc.begin(state, p)
+
+	// The cookie ties the callback to the browser that started the sign-in.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   int(oidcLoginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   s.secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	conf := s.oidc.oauth2Config(provider, p.redirectURL)
+	http.Redirect(w, r, conf.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(p.verifier)), http.StatusFound)
+}
+
+// oidcCallback completes a sign-in: it redeems the code, checks the ID
+// token and starts a session for the Ohara user linked to its subject,
+// creating that user on first sign-in.
+func (s *Server) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	state := q.Get("state")
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || c.Value != state {
+		http.Error(w, "sign-in state mismatch; start again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc/", MaxAge: -1})
+	p, ok := s.oidc.take(state)
+	if !ok {
+		http.Error(w, "sign-in expired; start again", http.StatusBadRequest)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		http.Error(w, "sign-in failed: "+strings.TrimSpace(e+" "+q.Get("error_description")), http.StatusUnauthorized)
+		return
+	}
+
+	ctx := s.oidc.context(r.Context())
+	provider, err := s.oidc.discover(ctx)
+	if err != nil {
+		requestLog(r).Error().Err(err).Str("issuer", s.oidc.cfg.Issuer).Msg("OIDC discovery failed")
+		http.Error(w, "identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+	tok, err := s.oidc.oauth2Config(provider, p.redirectURL).Exchange(ctx, q.Get("code"), oauth2.VerifierOption(p.verifier))
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("OIDC code exchange failed")
+		http.Error(w, "failed to redeem sign-in code", http.StatusBadGateway)
+		return
+	}
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "identity provider returned no ID token", http.StatusBadGateway)
+		return
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.oidc.cfg.ClientID}).Verify(ctx, raw)
+	if err != nil {
+		requestLog(r).Info().Err(err).Msg("Rejected OIDC ID token")
+		http.Error(w, "invalid ID token", http.StatusUnauthorized)
+		return
+	}
+	if idToken.Nonce != p.nonce {
+		http.Error(w, "invalid ID token nonce", http.StatusUnauthorized)
+		return
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		http.Error(w, "invalid ID token claims", http.StatusUnauthorized)
+		return
+	}
+	if err := s.oidc.checkDomain(claims); err != nil {
+		requestLog(r).Info().Err(err).Str("subject", idToken.Subject).Msg("Refused OIDC sign-in")
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	local, _, _ := strings.Cut(claims.Email, "@")
+	u, err := s.config.Users.Provision(idToken.Issuer, idToken.Subject, cmp.Or(claims.PreferredUsername, local, claims.Name))
+	if err != nil {
+		requestLog(r).Error().Err(err).Str("subject", idToken.Subject).Msg("Failed to map OIDC subject to a user")
+		http.Error(w, "failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	if err := s.startSession(w, r, u.Name); err != nil {
+		requestLog(r).Error().Err(err).Msg("Failed to create session")
+		http.Error(w, "failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	requestLog(r).Info().Str("user", u.Name).Msg("Signed in through OIDC")
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+type authMethodsPayload struct {
+	Password bool `json:"password"`
+	OIDC     bool `json:"oidc"`
+}
+
+// authMethods tells the sign-in dialog which options to offer.
+func (s *Server) authMethods(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, authMethodsPayload{
+		Password: s.config.Users != nil,
+		OIDC:     s.oidc != nil,
+	})
+}
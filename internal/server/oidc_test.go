@@ -0,0 +1,266 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tanq16/ohara/internal/auth"
+	"github.com/tanq16/ohara/internal/store"
+)
+
+// mockIssuer is an OpenID provider that hands out a code for each
+// authorization request it is told about, and ID tokens for the claims
+// the test sets.
+type mockIssuer struct {
+	t   *testing.T
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]mockGrant
+	claims map[string]any
+}
+
+// mockGrant is what an authorization request asked for.
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{t: t, key: key, codes: map[string]mockGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("GET /jwks", m.jwks)
+	mux.HandleFunc("POST /token", m.token)
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.srv.URL,
+		"authorization_endpoint":                m.srv.URL + "/authorize",
+		"token_endpoint":                        m.srv.URL + "/token",
+		"jwks_uri":                              m.srv.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := m.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "test",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// authorize records the authorization request behind a login redirect, as
+// if the user had signed in, and returns the code it was granted.
+func (m *mockIssuer) authorize(location string) string {
+	m.t.Helper()
+	u, err := url.Parse(location)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("login redirect %s has no S256 code challenge", location)
+	}
+	code := "code-" + q.Get("state")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code] = mockGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	return code
+}
+
+func (m *mockIssuer) setClaims(claims map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claims = claims
+}
+
+// token redeems a code once its PKCE verifier matches the challenge.
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	m.mu.Lock()
+	grant, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	claims := map[string]any{
+		"iss":   m.srv.URL,
+		"aud":   "ohara",
+		"sub":   "subject-1",
+		"nonce": grant.nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     m.sign(claims),
+	})
+}
+
+func (m *mockIssuer) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, sum[:])
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newOIDCServer(t *testing.T, issuer *mockIssuer) *Server {
+	t.Helper()
+	st, err := store.New(store.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(Config{
+		Users: auth.NewUserStore(t.TempDir()),
+		OIDC: &OIDCConfig{
+			Issuer:         issuer.srv.URL,
+			ClientID:       "ohara",
+			RedirectURL:    "http://ohara.test" + oidcCallbackPath,
+			AllowedDomains: []string{"example.com"},
+		},
+		SessionTTL: time.Hour,
+	}, st)
+}
+
+// startLogin begins a sign-in and returns its state cookie and the code
+// the issuer granted for it.
+func startLogin(t *testing.T, s *Server, issuer *mockIssuer) (*http.Cookie, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: status %d: %s", rec.Code, rec.Body)
+	}
+	var state *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			state = c
+		}
+	}
+	if state == nil {
+		t.Fatal("login set no state cookie")
+	}
+	return state, issuer.authorize(rec.Header().Get("Location"))
+}
+
+func callback(s *Server, cookie *http.Cookie, state, code string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?"+url.Values{"state": {state}, "code": {code}}.Encode(), nil)
+	r.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestOIDCCallback(t *testing.T) {
+	verified := map[string]any{"email": "ada@example.com", "email_verified": true, "preferred_username": "ada"}
+	tests := []struct {
+		name   string
+		claims map[string]any
+		// tamper changes the callback from what the login flow produced.
+		tamper func(t *testing.T, s *Server, issuer *mockIssuer, cookie *http.Cookie, code string) (*http.Cookie, string, string)
+		status int
+	}{
+		{"success", verified, nil, http.StatusFound},
+		{
+			name:   "state mismatch",
+			claims: verified,
+			tamper: func(t *testing.T, s *Server, issuer *mockIssuer, cookie *http.Cookie, code string) (*http.Cookie, string, string) {
+				return cookie, "forged", code
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "code from another login",
+			claims: verified,
+			tamper: func(t *testing.T, s *Server, issuer *mockIssuer, cookie *http.Cookie, code string) (*http.Cookie, string, string) {
+				// The other login's PKCE verifier does not match this code.
+				other, _ := startLogin(t, s, issuer)
+				return other, other.Value, code
+			},
+			status: http.StatusBadGateway,
+		},
+		{"nonce mismatch", map[string]any{"email": "ada@example.com", "email_verified": true, "nonce": "replayed"}, nil, http.StatusUnauthorized},
+		{"disallowed domain", map[string]any{"email": "ada@evil.test", "email_verified": true}, nil, http.StatusForbidden},
+		{"unverified email", map[string]any{"email": "ada@example.com", "email_verified": false}, nil, http.StatusForbidden},
+		{"email_verified missing", map[string]any{"email": "ada@example.com"}, nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newMockIssuer(t)
+			issuer.setClaims(tt.claims)
+			s := newOIDCServer(t, issuer)
+
+			cookie, code := startLogin(t, s, issuer)
+			state := cookie.Value
+			if tt.tamper != nil {
+				cookie, state, code = tt.tamper(t, s, issuer, cookie, code)
+			}
+			rec := callback(s, cookie, state, code)
+			if rec.Code != tt.status {
+				t.Fatalf("callback: status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusFound {
+				return
+			}
+
+			var user string
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == sessionCookie {
+					user, _ = s.sessions.Lookup(c.Value)
+				}
+			}
+			if user != "ada" {
+				t.Errorf("session for %q, want ada", user)
+			}
+			if rec := callback(s, cookie, state, code); rec.Code != http.StatusBadRequest {
+				t.Errorf("replayed callback: status %d, want 400", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// openapiYAML describes every route registered in setup; openapi_test.go
+// fails when one is missing.
+//
+//go:embed openapi.yaml
+var openapiYAML []byte
+
+// openapiJSON is the document converted to JSON once, on first request.
+var openapiJSON = sync.OnceValues(func() ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(openapiYAML, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+})
+
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	data, err := openapiJSON()
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("Failed to load the OpenAPI document")
+		writeError(w, http.StatusInternalServerError, "failed to load the OpenAPI document")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// routeMux is a ServeMux that remembers the patterns registered on it.
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
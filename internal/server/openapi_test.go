@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/store"
+)
+
+// undocumented are the routes serving the web UI itself rather than the API.
+var undocumented = map[string]bool{
+	"GET /{$}": true,
+	"/static/": true,
+}
+
+type openapiDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	st, err := store.New(store.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Optional routes are enabled so that they are checked too.
+	return New(Config{Metrics: true}, st)
+}
+
+func fetchOpenAPI(t *testing.T, s *Server) openapiDoc {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json: status %d: %s", rec.Code, rec.Body)
+	}
+	var doc openapiDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("GET /api/openapi.json: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("openapi version %q, want 3.x", doc.OpenAPI)
+	}
+	return doc
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	s := newTestServer(t)
+	doc := fetchOpenAPI(t, s)
+
+	registered := map[string]bool{}
+	for _, pattern := range s.mux.patterns {
+		if undocumented[pattern] {
+			continue
+		}
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			t.Errorf("route %q has no method; document it or add it to undocumented", pattern)
+			continue
+		}
+		registered[strings.ToLower(method)+" "+path] = true
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is not documented in openapi.yaml", pattern)
+		}
+	}
+
+	// Documented operations must exist too, so the spec cannot drift the
+	// other way.
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" || method == "summary" || method == "description" {
+				continue
+			}
+			if !registered[method+" "+path] {
+				t.Errorf("openapi.yaml documents %s %s, which is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
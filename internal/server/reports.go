@@ -2,29 +2,77 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+	"github.com/tanq16/ohara/internal/report"
+	"github.com/tanq16/ohara/internal/store"
 )
 
 func (s *Server) listReports(w http.ResponseWriter, r *http.Request) {
-	names, err := s.store.ListReports()
+	q := r.URL.Query()
+	reports, err := s.storeFor(r).ListReports(model.ReportFilter{
+		Statuses:  q["status"],
+		StartDate: q.Get("start_date"),
+		EndDate:   q.Get("end_date"),
+	})
 	if err != nil {
-		writeStoreError(w, err)
+		writeStoreError(w, r, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, names)
+	writeJSON(w, http.StatusOK, reports)
 }
 
 func (s *Server) getReport(w http.ResponseWriter, r *http.Request) {
 	filename := r.PathValue("filename")
 
-	content, err := s.store.GetReport(filename)
+	content, err := s.storeFor(r).GetReport(filename)
 	if err != nil {
-		writeStoreError(w, err)
+		writeStoreError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
-	w.Write([]byte(content))
+	switch r.URL.Query().Get("format") {
+	case "", "md":
+		w.Header().Set("ETag", reportETag(content))
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(content))
+	case "html":
+		fm, body := store.ParseReport(filename, content)
+		mermaidJS, _ := staticFiles.ReadFile("static/js/mermaid.min.js")
+		page, err := report.HTML(fm, body, mermaidJS)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	default:
+		writeError(w, http.StatusBadRequest, "unknown report format: "+r.URL.Query().Get("format")+" (expected md or html)")
+	}
+}
+
+func reportETag(content string) string {
+	return `"` + store.ReportVersion(content) + `"`
+}
+
+// ifMatchVersion reads the report version from an If-Match header. An
+// absent header or * places no condition on the update. Weak tags are
+// passed through as is and so never match, as If-Match requires.
+func ifMatchVersion(r *http.Request) (string, error) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return "", nil
+	}
+	if strings.Contains(h, ",") {
+		return "", fmt.Errorf("If-Match must name a single ETag")
+	}
+	return strings.Trim(h, `"`), nil
 }
 
 type reportPayload struct {
@@ -39,10 +87,166 @@ func (s *Server) createReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.store.CreateReport(p.Filename, p.Content); err != nil {
-		writeStoreError(w, err)
+	if err := s.storeFor(r).CreateReport(p.Filename, p.Content); err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusCreated, map[string]string{"filename": p.Filename})
 }
+
+// updateReport takes the new content either as raw Markdown (any text/*
+// Content-Type) or as JSON {"content": "..."}.
+func (s *Server) updateReport(w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("filename")
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var content string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/") {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read body: "+err.Error())
+			return
+		}
+		content = string(data)
+	} else {
+		var p reportPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		content = p.Content
+	}
+
+	if err := s.storeFor(r).UpdateReport(filename, content, version); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", reportETag(content))
+	writeJSON(w, http.StatusOK, map[string]string{"filename": filename})
+}
+
+func (s *Server) renameReport(w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("filename")
+
+	var p reportPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	if err := s.storeFor(r).RenameReport(filename, p.Filename); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	s.revokeReportShares(r, filename)
+
+	writeJSON(w, http.StatusOK, map[string]string{"filename": p.Filename})
+}
+
+func (s *Server) deleteReport(w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("filename")
+
+	if err := s.storeFor(r).DeleteReport(filename); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	s.revokeReportShares(r, filename)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeReportShares revokes the share links to a report that was deleted
+// or renamed, as they would otherwise share whatever is next saved under
+// its name. The report change already happened, so failures are only
+// logged.
+func (s *Server) revokeReportShares(r *http.Request, filename string) {
+	if s.config.Shares == nil {
+		return
+	}
+	if err := s.config.Shares.RevokeReport(requestOwner(r), filename); err != nil {
+		requestLog(r).Error().Err(err).Str("report", filename).Msg("Failed to revoke share links to report")
+	}
+}
+
+func (s *Server) listTemplates(w http.ResponseWriter, r *http.Request) {
+	names, err := s.storeFor(r).ListTemplates()
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+// generateReport renders a template over the touchpoints a request selects
+// and saves the result as a new report. Mistakes in the request come back
+// as store.ErrValidation.
+func generateReport(st Storer, req model.ReportRequest, author string) (filename, content string, err error) {
+	if req.Template == "" {
+		req.Template = "brag"
+	}
+
+	src, err := st.GetTemplate(req.Template)
+	if err != nil {
+		return "", "", err
+	}
+
+	page, err := st.ListTouchpoints(model.TouchpointFilter{
+		Categories: req.Categories,
+		Tags:       req.Tags,
+		StartDate:  req.StartDate,
+		EndDate:    req.EndDate,
+		Search:     req.Search,
+		Query:      req.Query,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := report.New(req, page.Touchpoints)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", err, store.ErrValidation)
+	}
+	data.Author = author
+	content, err = report.Render(req.Template, src, data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render template: %w: %w", err, store.ErrValidation)
+	}
+
+	filename = req.Filename
+	if filename != "" {
+		return filename, content, st.CreateReport(filename, content)
+	}
+	// Number default names apart when several land in the same second.
+	base := req.Template + "-" + time.Now().UTC().Format("20060102-150405")
+	filename = base + ".md"
+	for i := 2; ; i++ {
+		err = st.CreateReport(filename, content)
+		if !errors.Is(err, store.ErrAlreadyExists) || i > 100 {
+			return filename, content, err
+		}
+		filename = fmt.Sprintf("%s-%d.md", base, i)
+	}
+}
+
+func (s *Server) generateReport(w http.ResponseWriter, r *http.Request) {
+	var req model.ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	filename, content, err := generateReport(s.storeFor(r), req, requestAuthor(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, reportPayload{Filename: filename, Content: content})
+}
@@ -1,57 +1,173 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
-	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/tanq16/ohara/internal/auth"
 	"github.com/tanq16/ohara/internal/model"
 	"github.com/tanq16/ohara/internal/store"
+	"github.com/tanq16/ohara/internal/webhook"
 )
 
 type Storer interface {
-	ListTouchpoints(category, tag, startDate string) ([]model.Touchpoint, error)
-	CreateTouchpoint(input model.TouchpointInput) (model.Touchpoint, error)
-	UpdateTouchpoint(id string, input model.TouchpointInput) (model.Touchpoint, error)
-	DeleteTouchpoint(id string) error
+	ListTouchpoints(f model.TouchpointFilter) (model.TouchpointPage, error)
+	CreateTouchpoint(input model.TouchpointInput, author string) (model.Touchpoint, error)
+	UpdateTouchpoint(id string, input model.TouchpointInput, author string) (model.Touchpoint, error)
+	DeleteTouchpoint(id, author string) error
+	TouchpointHistory(id string) ([]model.Revision, error)
+	RestoreRevision(id string, rev int, author string) (model.Touchpoint, error)
+	ListTrash() ([]model.Touchpoint, error)
+	RestoreTouchpoint(id, author string) (model.Touchpoint, error)
+	PurgeTouchpoint(id string) error
+	PurgeTrash(before time.Time) (int, error)
+	ImportTouchpoints(rows []model.ImportRow, opts model.ImportOptions, author string) (model.ImportReport, error)
 	GetMetadata() (model.Metadata, error)
 	AddCategory(name string) error
 	RemoveCategory(name string) error
 	AddTag(name string) error
 	RemoveTag(name string) error
-	ListReports() ([]string, error)
+	ListReports(f model.ReportFilter) ([]model.ReportInfo, error)
 	GetReport(filename string) (string, error)
 	CreateReport(filename, content string) error
+	UpdateReport(filename, content, version string) error
+	RenameReport(filename, newFilename string) error
+	DeleteReport(filename string) error
+	ListTemplates() ([]string, error)
+	GetTemplate(name string) (string, error)
+	// Counts reports how much the store holds without reading all of it.
+	Counts() (model.Counts, error)
+	// Check reports whether the store is able to serve requests.
+	Check() error
 }
 
 type Config struct {
 	Port int
+	// Version is reported to MCP clients.
+	Version string
+	// TrashRetention is how long deleted touchpoints stay restorable; zero
+	// keeps them until purged by hand.
+	TrashRetention time.Duration
+	// Tokens and Users guard the API once any token or account exists; nil
+	// leaves it open.
+	Tokens *auth.TokenStore
+	Users  *auth.UserStore
+	// SessionTTL is how long a browser login lasts.
+	SessionTTL time.Duration
+	// OpenUserStore opens the Storer holding an account's data.
+	OpenUserStore func(user string) (Storer, error)
+	// Metrics serves Prometheus metrics at /metrics, which needs no
+	// credentials.
+	Metrics bool
+	// PublicURL is the scheme and host, without a trailing slash, that
+	// share links and the default OIDC redirect point at; by default they
+	// are taken from each request.
+	PublicURL string
+	// OIDC enables single sign-on; it needs Users to map identities to.
+	OIDC *OIDCConfig
+	// Shares holds read-only share links; nil disables them.
+	Shares *auth.ShareStore
+	// Webhooks are managed through the API, and receive changes through
+	// Events; nil disables them. Run closes it once the server has stopped.
+	Webhooks *webhook.Dispatcher
+	// Events streams changes to clients of /api/events. The stores must
+	// announce their changes through its Hook; if nil, nothing is streamed.
+	Events *Events
+	// ReadTimeout, WriteTimeout and IdleTimeout bound each connection; zero
+	// means no limit. Exports lift the write timeout while they stream.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long Run waits for in-flight requests once its
+	// context ends.
+	ShutdownTimeout time.Duration
+	// TLSCert and TLSKey are PEM files to serve HTTPS with.
+	TLSCert string
+	TLSKey  string
 }
 
+// readHeaderTimeout stops clients from holding connections open by sending
+// headers slowly, whatever the other timeouts are.
+const readHeaderTimeout = 10 * time.Second
+
 type Server struct {
 	config Config
-	store  Storer
-	mux    *http.ServeMux
+	// store is the shared namespace, used while there are no accounts and
+	// by tokens not tied to one.
+	store    Storer
+	stores   *storePool
+	sessions *auth.Sessions
+	oidc     *oidcLogin
+	metrics  *metrics
+	events   *Events
+	mux      *routeMux
 }
 
 func New(cfg Config, st Storer) *Server {
 	s := &Server{
-		config: cfg,
-		store:  st,
-		mux:    http.NewServeMux(),
+		config:   cfg,
+		stores:   &storePool{},
+		sessions: auth.NewSessions(cfg.SessionTTL),
+		events:   cfg.Events,
+		mux:      &routeMux{ServeMux: http.NewServeMux()},
+	}
+	if s.events == nil {
+		s.events = NewEvents(nil)
+	}
+	s.metrics = newMetrics(s)
+	s.store = s.metrics.instrument(st)
+	if cfg.OpenUserStore != nil {
+		s.stores.open = func(user string) (Storer, error) {
+			st, err := cfg.OpenUserStore(user)
+			if err != nil {
+				return nil, err
+			}
+			return s.metrics.instrument(st), nil
+		}
+	}
+	if cfg.OIDC != nil {
+		s.oidc = &oidcLogin{cfg: *cfg.OIDC}
 	}
 	s.setup()
 	return s
 }
 
 func (s *Server) setup() {
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("GET /readyz", s.readyz)
+	if s.config.Metrics {
+		s.mux.Handle("GET /metrics", s.metrics.handler())
+	}
+	s.mux.HandleFunc("GET /api/openapi.json", s.getOpenAPI)
+	s.mux.Handle("POST "+mcpPath, s.mcpHandler())
+
+	s.mux.HandleFunc("POST /api/login", s.login)
+	s.mux.HandleFunc("POST /api/logout", s.logout)
+	s.mux.HandleFunc("GET /api/session", s.getSession)
+	s.mux.HandleFunc("GET /api/auth/methods", s.authMethods)
+	s.mux.HandleFunc("GET /api/auth/oidc/login", s.oidcStart)
+	s.mux.HandleFunc("GET "+oidcCallbackPath, s.oidcCallback)
+
 	s.mux.HandleFunc("GET /api/touchpoints", s.listTouchpoints)
 	s.mux.HandleFunc("POST /api/touchpoints", s.createTouchpoint)
 	s.mux.HandleFunc("PUT /api/touchpoints/{id}", s.updateTouchpoint)
 	s.mux.HandleFunc("DELETE /api/touchpoints/{id}", s.deleteTouchpoint)
+	s.mux.HandleFunc("GET /api/touchpoints/{id}/history", s.touchpointHistory)
+	s.mux.HandleFunc("POST /api/touchpoints/{id}/restore/{rev}", s.restoreRevision)
+
+	s.mux.HandleFunc("POST /api/import", s.importTouchpoints)
+	s.mux.HandleFunc("GET /api/export", s.exportTouchpoints)
+
+	s.mux.HandleFunc("GET /api/trash", s.listTrash)
+	s.mux.HandleFunc("POST /api/trash/{id}/restore", s.restoreTouchpoint)
+	s.mux.HandleFunc("DELETE /api/trash/{id}", s.purgeTouchpoint)
+	s.mux.HandleFunc("DELETE /api/trash", s.emptyTrash)
 
 	s.mux.HandleFunc("GET /api/metadata", s.getMetadata)
 	s.mux.HandleFunc("POST /api/metadata/categories", s.addCategory)
@@ -62,10 +178,29 @@ func (s *Server) setup() {
 	s.mux.HandleFunc("GET /api/reports", s.listReports)
 	s.mux.HandleFunc("GET /api/reports/{filename}", s.getReport)
 	s.mux.HandleFunc("POST /api/reports", s.createReport)
+	s.mux.HandleFunc("PUT /api/reports/{filename}", s.updateReport)
+	s.mux.HandleFunc("PATCH /api/reports/{filename}", s.renameReport)
+	s.mux.HandleFunc("DELETE /api/reports/{filename}", s.deleteReport)
+	s.mux.HandleFunc("POST /api/reports/generate", s.generateReport)
+	s.mux.HandleFunc("GET /api/templates", s.listTemplates)
+
+	s.mux.HandleFunc("GET /api/shares", s.listShares)
+	s.mux.HandleFunc("POST /api/shares", s.createShare)
+	s.mux.HandleFunc("DELETE /api/shares/{id}", s.revokeShare)
+	s.mux.HandleFunc("GET /api/events", s.streamEvents)
+	s.mux.HandleFunc("GET /api/webhooks", s.listWebhooks)
+	s.mux.HandleFunc("POST /api/webhooks", s.createWebhook)
+	s.mux.HandleFunc("DELETE /api/webhooks/{id}", s.deleteWebhook)
+	s.mux.HandleFunc("GET /api/webhooks/{id}/deliveries", s.listWebhookDeliveries)
+	s.mux.HandleFunc("POST /api/webhooks/{id}/test", s.testWebhook)
+
+	s.mux.HandleFunc("GET /share/{token}", s.viewShare)
+	s.mux.HandleFunc("GET /share/{token}/touchpoints", s.sharedTouchpoints)
+	s.mux.HandleFunc("GET /share/{token}/report", s.sharedReport)
 
 	sub, err := fs.Sub(staticFiles, "static")
 	if err != nil {
-		log.Fatalf("ERROR [server] failed to create static sub-filesystem: %v", err)
+		serverLog().Fatal().Err(err).Msg("Failed to create static sub-filesystem")
 	}
 	s.mux.Handle("/static/", http.StripPrefix("/static/", http.FileServerFS(sub)))
 
@@ -82,17 +217,91 @@ func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
-func withLogging(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("INFO [server] %s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-	})
+// Run serves until ctx ends, then stops accepting connections and waits up
+// to ShutdownTimeout for in-flight requests, so no write is cut off halfway.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.stores.close()
+	if s.config.Webhooks != nil {
+		defer s.config.Webhooks.Close()
+	}
+
+	if s.config.TrashRetention > 0 {
+		go s.purgeTrashLoop(ctx)
+	}
+
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.config.Port),
+		Handler:           withLogging(s.withMetrics(s.withAuth(s.mux))),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       s.config.ReadTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+		ErrorLog:          httpErrorLog(),
+	}
+	srv.RegisterOnShutdown(s.events.bus.close)
+
+	errc := make(chan error, 1)
+	go func() {
+		if s.config.TLSCert != "" {
+			serverLog().Info().Str("addr", srv.Addr).Msg("Starting HTTPS server")
+			errc <- srv.ListenAndServeTLS(s.config.TLSCert, s.config.TLSKey)
+			return
+		}
+		serverLog().Info().Str("addr", srv.Addr).Msg("Starting HTTP server")
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	serverLog().Info().Dur("timeout", s.config.ShutdownTimeout).Msg("Shutting down; waiting for in-flight requests")
+	shutdownCtx, stop := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer stop()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	serverLog().Info().Msg("Stopped")
+	return nil
+}
+
+// requestAuthor names whoever made a change, for revision history. The
+// signed-in user or token name takes precedence over the X-Ohara-Author
+// header.
+func requestAuthor(r *http.Request) string {
+	if id, ok := requestIdentity(r); ok {
+		return id.name
+	}
+	if author := r.Header.Get("X-Ohara-Author"); author != "" {
+		return author
+	}
+	return "anonymous"
+}
+
+// origin is the scheme and host for absolute links back to the server:
+// PublicURL when set, or else whatever the client says it reached, which
+// anyone can forge with a Host header.
+func (s *Server) origin(r *http.Request) string {
+	if s.config.PublicURL != "" {
+		return s.config.PublicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
 }
 
-func (s *Server) Run() error {
-	addr := fmt.Sprintf(":%d", s.config.Port)
-	log.Printf("INFO [server] Starting on %s", addr)
-	return http.ListenAndServe(addr, withLogging(s.mux))
+// secure reports whether the client reached the server over HTTPS, by the
+// same reckoning as origin, so cookies get the Secure flag behind a TLS
+// terminating proxy too.
+func (s *Server) secure(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(s.origin(r), "https://")
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -105,16 +314,18 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, map[string]string{"error": msg})
 }
 
-func writeStoreError(w http.ResponseWriter, err error) {
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
 	case errors.Is(err, store.ErrNotFound):
 		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, store.ErrConflict):
+		writeError(w, http.StatusPreconditionFailed, err.Error())
 	case errors.Is(err, store.ErrValidation),
 		errors.Is(err, store.ErrAlreadyExists),
 		errors.Is(err, store.ErrInvalidFilename):
 		writeError(w, http.StatusBadRequest, err.Error())
 	default:
-		log.Printf("ERROR [server] %v", err)
+		requestLog(r).Error().Err(err).Msg("Request failed")
 		writeError(w, http.StatusInternalServerError, err.Error())
 	}
 }
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tanq16/ohara/internal/auth"
+	"github.com/tanq16/ohara/internal/exporter"
+	"github.com/tanq16/ohara/internal/model"
+	"github.com/tanq16/ohara/internal/report"
+	"github.com/tanq16/ohara/internal/store"
+)
+
+const (
+	defaultShareTTL = 7 * 24 * time.Hour
+	maxShareTTL     = 365 * 24 * time.Hour
+)
+
+func (s *Server) shareURL(r *http.Request, token string) string {
+	return s.origin(r) + "/share/" + token
+}
+
+// shareFilter turns a share's saved filter into a touchpoint filter.
+func shareFilter(f *model.ShareFilter) model.TouchpointFilter {
+	if f == nil {
+		return model.TouchpointFilter{}
+	}
+	return model.TouchpointFilter{
+		Categories: f.Categories,
+		Tags:       f.Tags,
+		StartDate:  f.StartDate,
+		EndDate:    f.EndDate,
+		Search:     f.Search,
+		Query:      f.Query,
+	}
+}
+
+// requestOwner is the namespace a request's data lives in.
+func requestOwner(r *http.Request) string {
+	id, _ := requestIdentity(r)
+	return id.user
+}
+
+func (s *Server) listShares(w http.ResponseWriter, r *http.Request) {
+	if s.config.Shares == nil {
+		writeError(w, http.StatusNotFound, "share links are disabled")
+		return
+	}
+	shares, tokens, err := s.config.Shares.List(requestOwner(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	for i := range shares {
+		shares[i].URL = s.shareURL(r, tokens[i])
+	}
+	writeJSON(w, http.StatusOK, shares)
+}
+
+func (s *Server) createShare(w http.ResponseWriter, r *http.Request) {
+	if s.config.Shares == nil {
+		writeError(w, http.StatusNotFound, "share links are disabled")
+		return
+	}
+	var req model.ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	ttl := defaultShareTTL
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > maxShareTTL {
+			writeError(w, http.StatusBadRequest, "invalid expires_in: "+req.ExpiresIn+" (expected a positive duration up to 8760h)")
+			return
+		}
+		ttl = d
+	}
+
+	st := s.storeFor(r)
+	if req.Report != "" {
+		if req.Filter != nil {
+			writeError(w, http.StatusBadRequest, "share either a report or a filter, not both")
+			return
+		}
+		if _, err := st.GetReport(req.Report); err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+	} else {
+		// Listing a single match checks the filter's dates and query up front.
+		f := shareFilter(req.Filter)
+		f.Limit = 1
+		if _, err := st.ListTouchpoints(f); err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+	}
+
+	share, token, err := s.config.Shares.Create(requestOwner(r), model.Share{
+		Title:     req.Title,
+		Report:    req.Report,
+		Filter:    req.Filter,
+		CreatedBy: requestAuthor(r),
+	}, ttl)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	share.URL = s.shareURL(r, token)
+	writeJSON(w, http.StatusCreated, share)
+}
+
+func (s *Server) revokeShare(w http.ResponseWriter, r *http.Request) {
+	if s.config.Shares == nil {
+		writeError(w, http.StatusNotFound, "share links are disabled")
+		return
+	}
+	id := r.PathValue("id")
+	err := s.config.Shares.Revoke(requestOwner(r), id)
+	if errors.Is(err, auth.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "share "+id+": not found")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveShare checks a share link and returns it with the Storer it reads
+// from. It writes the error response itself when the link is unusable.
+func (s *Server) resolveShare(w http.ResponseWriter, r *http.Request) (model.Share, Storer, bool) {
+	// Share URLs are credentials; keep them out of caches, search engines
+	// and Referer headers.
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	if s.config.Shares == nil {
+		http.NotFound(w, r)
+		return model.Share{}, nil, false
+	}
+	share, owner, err := s.config.Shares.Resolve(r.PathValue("token"))
+	if errors.Is(err, auth.ErrInvalidToken) {
+		writeError(w, http.StatusNotFound, "this share link is invalid, expired or revoked")
+		return model.Share{}, nil, false
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return model.Share{}, nil, false
+	}
+
+	if owner == "" {
+		return share, s.store, true
+	}
+	if s.config.Users == nil {
+		writeError(w, http.StatusNotFound, "this share link is invalid, expired or revoked")
+		return model.Share{}, nil, false
+	}
+	if _, err := s.config.Users.Get(owner); err != nil {
+		writeError(w, http.StatusNotFound, "this share link is invalid, expired or revoked")
+		return model.Share{}, nil, false
+	}
+	st, err := s.stores.get(owner)
+	if err != nil {
+		requestLog(r).Error().Err(err).Str("user", owner).Msg("Failed to open shared data")
+		writeError(w, http.StatusInternalServerError, "failed to open shared data")
+		return model.Share{}, nil, false
+	}
+	return share, st, true
+}
+
+// viewShare renders a share link as a standalone read-only page.
+func (s *Server) viewShare(w http.ResponseWriter, r *http.Request) {
+	share, st, ok := s.resolveShare(w, r)
+	if !ok {
+		return
+	}
+
+	var fm model.ReportFrontMatter
+	var body string
+	if share.Report != "" {
+		content, err := st.GetReport(share.Report)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		fm, body = store.ParseReport(share.Report, content)
+		fm.Title = cmp.Or(share.Title, fm.Title)
+	} else {
+		var buf bytes.Buffer
+		if err := exporter.Export(&buf, exporter.Markdown, shareFilter(share.Filter), st.ListTouchpoints); err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		fm.Title = cmp.Or(share.Title, "Touchpoints")
+		body = "# " + fm.Title + "\n" + strings.TrimPrefix(buf.String(), "# Touchpoints\n")
+		fm.Author = share.CreatedBy
+		if f := share.Filter; f != nil && (f.StartDate != "" || f.EndDate != "") {
+			fm.Period = &model.ReportPeriod{Start: f.StartDate, End: f.EndDate}
+		}
+	}
+	fm.Status = "read-only, expires " + share.ExpiresAt
+
+	mermaidJS, _ := staticFiles.ReadFile("static/js/mermaid.min.js")
+	page, err := report.HTML(fm, body, mermaidJS)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+type sharedTouchpointsPayload struct {
+	model.TouchpointPage
+	Share model.Share `json:"share"`
+}
+
+// sharedTouchpoints lists a share link's touchpoints as JSON. Only limit and
+// cursor can be set by the caller; the saved filter always applies.
+func (s *Server) sharedTouchpoints(w http.ResponseWriter, r *http.Request) {
+	share, st, ok := s.resolveShare(w, r)
+	if !ok {
+		return
+	}
+	if share.Report != "" {
+		writeError(w, http.StatusNotFound, "this link shares a report; see /report")
+		return
+	}
+
+	q := r.URL.Query()
+	paging, err := filterFromQuery(map[string][]string{"limit": q["limit"], "cursor": q["cursor"]})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f := shareFilter(share.Filter)
+	f.Limit, f.Cursor = paging.Limit, paging.Cursor
+	page, err := st.ListTouchpoints(f)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sharedTouchpointsPayload{TouchpointPage: page, Share: share})
+}
+
+// sharedReport returns a shared report's Markdown and front matter as JSON.
+func (s *Server) sharedReport(w http.ResponseWriter, r *http.Request) {
+	share, st, ok := s.resolveShare(w, r)
+	if !ok {
+		return
+	}
+	if share.Report == "" {
+		writeError(w, http.StatusNotFound, "this link shares touchpoints; see /touchpoints")
+		return
+	}
+	content, err := st.GetReport(share.Report)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	fm, _ := store.ParseReport(share.Report, content)
+	writeJSON(w, http.StatusOK, model.ReportDocument{Filename: share.Report, Content: content, ReportFrontMatter: fm})
+}
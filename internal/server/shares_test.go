@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/auth"
+	"github.com/tanq16/ohara/internal/model"
+	"github.com/tanq16/ohara/internal/store"
+)
+
+func serve(t *testing.T, s *Server, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if rec.Code >= 300 && method != http.MethodGet {
+		t.Fatalf("%s %s: status %d: %s", method, path, rec.Code, rec.Body)
+	}
+	return rec
+}
+
+// TestShareOutlivesNoReport checks that deleting or renaming a report
+// revokes its links, so a report later saved under the same name is not
+// shared by them.
+func TestShareOutlivesNoReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(t *testing.T, s *Server)
+	}{
+		{"delete", func(t *testing.T, s *Server) {
+			serve(t, s, http.MethodDelete, "/api/reports/q1.md", "")
+		}},
+		{"rename", func(t *testing.T, s *Server) {
+			serve(t, s, http.MethodPatch, "/api/reports/q1.md", `{"filename": "q1-old.md"}`)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			st, err := store.New(store.Config{DataDir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := New(Config{Shares: auth.NewShareStore(dir)}, st)
+
+			serve(t, s, http.MethodPost, "/api/reports", `{"filename": "q1.md", "content": "# Shared"}`)
+			serve(t, s, http.MethodPost, "/api/reports", `{"filename": "q2.md", "content": "# Other"}`)
+			var shares []model.Share
+			for _, report := range []string{"q1.md", "q2.md"} {
+				var share model.Share
+				rec := serve(t, s, http.MethodPost, "/api/shares", `{"report": "`+report+`"}`)
+				if err := json.Unmarshal(rec.Body.Bytes(), &share); err != nil {
+					t.Fatal(err)
+				}
+				shares = append(shares, share)
+			}
+			path := func(share model.Share) string {
+				return strings.TrimPrefix(share.URL, "http://example.com") + "/report"
+			}
+			if rec := serve(t, s, http.MethodGet, path(shares[0]), ""); rec.Code != http.StatusOK {
+				t.Fatalf("share before removing: status %d: %s", rec.Code, rec.Body)
+			}
+
+			tt.remove(t, s)
+			serve(t, s, http.MethodPost, "/api/reports", `{"filename": "q1.md", "content": "# Private"}`)
+			if rec := serve(t, s, http.MethodGet, path(shares[0]), ""); rec.Code != http.StatusNotFound {
+				t.Errorf("share after removing: status %d: %s", rec.Code, rec.Body)
+			}
+			if rec := serve(t, s, http.MethodGet, path(shares[1]), ""); rec.Code != http.StatusOK {
+				t.Errorf("other report's share: status %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
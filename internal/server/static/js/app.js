@@ -43,11 +43,122 @@ document.addEventListener("DOMContentLoaded", () => {
     },
   });
 
+  document.getElementById("logout-btn").addEventListener("click", signOut);
+
   initDashboard();
+  loadSession();
+  watchEvents();
 });
 
+const TOKEN_KEY = "ohara-token";
+
+// apiFetch sends the saved API token, if any, and asks the user to sign in
+// when the server turns the request away.
+async function apiFetch(url, options = {}) {
+  for (;;) {
+    const token = localStorage.getItem(TOKEN_KEY);
+    const headers = { ...(options.headers || {}) };
+    if (token) headers.Authorization = `Bearer ${token}`;
+    const res = await fetch(url, { ...options, headers });
+    if (res.status !== 401) return res;
+
+    if (token) localStorage.removeItem(TOKEN_KEY);
+    if (!(await signIn())) return res;
+  }
+}
+
+let pendingSignIn = null;
+
+// signIn shows the sign-in dialog and resolves to true once the user has
+// logged in or entered a token. Concurrent callers share one dialog.
+function signIn() {
+  if (pendingSignIn) return pendingSignIn;
+
+  const modal = document.getElementById("login-modal");
+  const form = document.getElementById("login-form");
+  const errorEl = document.getElementById("login-error");
+  const tokenBtn = document.getElementById("login-token");
+
+  pendingSignIn = new Promise((resolve) => {
+    const finish = (ok) => {
+      form.removeEventListener("submit", onSubmit);
+      tokenBtn.removeEventListener("click", onToken);
+      modal.classList.remove("show");
+      form.reset();
+      errorEl.classList.add("hidden");
+      pendingSignIn = null;
+      if (ok) loadSession();
+      resolve(ok);
+    };
+
+    const onSubmit = async (e) => {
+      e.preventDefault();
+      const res = await fetch("/api/login", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({
+          username: document.getElementById("login-username").value.trim(),
+          password: document.getElementById("login-password").value,
+        }),
+      });
+      if (res.ok) {
+        finish(true);
+        return;
+      }
+      const err = await res.json().catch(() => ({ error: res.statusText }));
+      errorEl.textContent = err.error || res.statusText;
+      errorEl.classList.remove("hidden");
+    };
+
+    const onToken = () => {
+      const entered = prompt("API token:");
+      if (!entered || !entered.trim()) return;
+      localStorage.setItem(TOKEN_KEY, entered.trim());
+      finish(true);
+    };
+
+    form.addEventListener("submit", onSubmit);
+    tokenBtn.addEventListener("click", onToken);
+    fetch("/api/auth/methods")
+      .then((res) => res.json())
+      .then((methods) => {
+        const sso = document.getElementById("login-sso");
+        sso.classList.toggle("hidden", !methods.oidc);
+        sso.classList.toggle("flex", methods.oidc);
+      })
+      .catch(() => {});
+    modal.classList.add("show");
+    document.getElementById("login-username").focus();
+  });
+  return pendingSignIn;
+}
+
+// loadSession shows who is signed in, if the server requires signing in.
+async function loadSession() {
+  const info = document.getElementById("session-info");
+  try {
+    const session = await api("/session");
+    if (!session.auth) return;
+    const label = session.user && session.user !== session.name ? `${session.user} (${session.name})` : session.name;
+    document.getElementById("session-name").innerHTML =
+      `<i data-lucide="user" class="w-4 h-4"></i>${escapeHtml(label)}`;
+    lucide.createIcons({ nodes: info.querySelectorAll("[data-lucide]") });
+    info.classList.remove("hidden");
+    info.classList.add("flex");
+  } catch {
+    info.classList.add("hidden");
+    info.classList.remove("flex");
+  }
+}
+
+async function signOut() {
+  localStorage.removeItem(TOKEN_KEY);
+  await fetch("/api/logout", { method: "POST" });
+  location.reload();
+}
+
 async function api(path, options = {}) {
-  const res = await fetch(`/api${path}`, {
+  const res = await apiFetch(`/api${path}`, {
     headers: { "Content-Type": "application/json" },
     ...options,
   });
@@ -61,6 +172,28 @@ async function api(path, options = {}) {
   return res.json();
 }
 
+// createShare asks how long a read-only link should last, creates it and
+// offers it for copying.
+async function createShare(request) {
+  const days = prompt("Share a read-only link for how many days?", "7");
+  if (days === null) return;
+  const n = parseInt(days, 10);
+  if (!(n > 0)) {
+    alert("Enter a number of days.");
+    return;
+  }
+  try {
+    const share = await api("/shares", {
+      method: "POST",
+      body: JSON.stringify({ ...request, expires_in: `${n * 24}h` }),
+    });
+    await navigator.clipboard?.writeText(share.url).catch(() => {});
+    prompt("Share link (copied to the clipboard if allowed). Revoke it with DELETE /api/shares/" + share.id, share.url);
+  } catch (err) {
+    alert("Failed to create share link: " + err.message);
+  }
+}
+
 function formatDate(isoString) {
   return new Date(isoString).toLocaleString(undefined, {
     year: "numeric",
@@ -37,10 +37,9 @@ async function initDashboard() {
   renderTouchpointList();
 }
 
-function renderTimelineChart(tps) {
-  const ctx = document.getElementById("chart-timeline");
-  if (timelineChart) timelineChart.destroy();
-
+// timelineData counts touchpoints, categories and tags per month over the
+// last twelve months.
+function timelineData(tps) {
   const now = new Date();
   const labels = [];
   const monthKeys = [];
@@ -69,9 +68,34 @@ function renderTimelineChart(tps) {
     }
   });
 
-  const counts = monthKeys.map((k) => countByMonth[k]);
-  const diversity = monthKeys.map((k) => categoriesByMonth[k].size);
-  const tagDiversity = monthKeys.map((k) => tagsByMonth[k].size);
+  return {
+    labels,
+    counts: monthKeys.map((k) => countByMonth[k]),
+    diversity: monthKeys.map((k) => categoriesByMonth[k].size),
+    tagDiversity: monthKeys.map((k) => tagsByMonth[k].size),
+  };
+}
+
+// refreshTimelineChart updates the chart's data in place, so live updates
+// animate instead of redrawing it from scratch.
+function refreshTimelineChart(tps) {
+  if (!timelineChart) {
+    renderTimelineChart(tps);
+    return;
+  }
+  const { labels, counts, diversity, tagDiversity } = timelineData(tps);
+  timelineChart.data.labels = labels;
+  timelineChart.data.datasets[0].data = counts;
+  timelineChart.data.datasets[1].data = diversity;
+  timelineChart.data.datasets[2].data = tagDiversity;
+  timelineChart.update();
+}
+
+function renderTimelineChart(tps) {
+  const ctx = document.getElementById("chart-timeline");
+  if (timelineChart) timelineChart.destroy();
+
+  const { labels, counts, diversity, tagDiversity } = timelineData(tps);
 
   const legendMarginPlugin = {
     id: "legendMargin",
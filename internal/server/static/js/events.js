@@ -0,0 +1,97 @@
+// Live updates. The server streams changes to this namespace's data as
+// Server-Sent Events; fetch is used instead of EventSource so the API token
+// can be sent, and reconnects resume from the last event seen.
+let lastEventId = "";
+let dashboardTimer = null;
+
+async function watchEvents() {
+  for (;;) {
+    try {
+      const headers = { Accept: "text/event-stream" };
+      if (lastEventId) headers["Last-Event-ID"] = lastEventId;
+      const res = await apiFetch("/api/events", { headers });
+      // Signed out or not allowed; retrying would only ask again.
+      if (!res.ok) return;
+      await readEvents(res.body);
+    } catch (err) {
+      console.warn("Event stream interrupted:", err);
+    }
+    await new Promise((resolve) => setTimeout(resolve, 3000));
+  }
+}
+
+async function readEvents(body) {
+  const reader = body.pipeThrough(new TextDecoderStream()).getReader();
+  let buf = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) return;
+    buf += value;
+    let end;
+    while ((end = buf.indexOf("\n\n")) >= 0) {
+      const frame = buf.slice(0, end);
+      buf = buf.slice(end + 2);
+      let type = "message";
+      let data = "";
+      for (const line of frame.split("\n")) {
+        if (line.startsWith("id:")) lastEventId = line.slice(3).trim();
+        else if (line.startsWith("event:")) type = line.slice(6).trim();
+        else if (line.startsWith("data:")) data += line.slice(5).trim();
+      }
+      if (data) handleEvent(type, JSON.parse(data).data);
+    }
+  }
+}
+
+function activeTab() {
+  return document.querySelector(".tab.active")?.dataset.tab;
+}
+
+function handleEvent(type, data) {
+  switch (type) {
+    case "touchpoint.created":
+    case "touchpoint.updated": {
+      const i = allTouchpoints.findIndex((tp) => tp.id === data.id);
+      if (i >= 0) allTouchpoints[i] = data;
+      else allTouchpoints.push(data);
+      refreshDashboard();
+      break;
+    }
+    case "touchpoint.deleted":
+      allTouchpoints = allTouchpoints.filter((tp) => tp.id !== data.id);
+      refreshDashboard();
+      break;
+    case "touchpoints.imported":
+    case "metadata.updated":
+      reloadTouchpoints();
+      if (type === "metadata.updated" && activeTab() === "metadata") loadMetadata();
+      break;
+    case "report.published":
+    case "report.updated":
+    case "report.renamed":
+    case "report.deleted":
+      if (activeTab() === "reports") loadReportsList();
+      break;
+    case "reset":
+      // Events were missed, so nothing on screen can be trusted.
+      reloadTouchpoints();
+      if (activeTab() === "metadata") loadMetadata();
+      if (activeTab() === "reports") loadReportsList();
+      break;
+  }
+}
+
+// refreshDashboard redraws the list and updates the chart in place,
+// batching bursts of events into one redraw.
+function refreshDashboard() {
+  clearTimeout(dashboardTimer);
+  dashboardTimer = setTimeout(() => {
+    renderTouchpointList();
+    refreshTimelineChart(getFilteredTouchpoints());
+  }, 100);
+}
+
+async function reloadTouchpoints() {
+  await loadTouchpoints();
+  refreshDashboard();
+}
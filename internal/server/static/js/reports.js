@@ -39,16 +39,17 @@ async function loadReportsList() {
   const container = document.getElementById("reports-list");
 
   try {
-    const names = await api("/reports");
-    if (!names.length) {
+    const reports = await api("/reports");
+    if (!reports.length) {
       container.innerHTML = '<div class="empty-state py-8">No reports yet.</div>';
       return;
     }
-    container.innerHTML = names
-      .map(
-        (name) =>
-          `<div class="report-item" data-filename="${escapeHtml(name)}" onclick="viewReport('${escapeHtml(name)}', this)">${escapeHtml(name.replace(/\.md$/, ""))}</div>`
-      )
+    container.innerHTML = reports
+      .map((r) => {
+        const name = escapeHtml(r.filename);
+        const status = r.status ? ` <span class="opacity-60 text-xs">· ${escapeHtml(r.status)}</span>` : "";
+        return `<div class="report-item" data-filename="${name}" title="${name}" onclick="viewReport('${name}', this)">${escapeHtml(r.title)}${status}</div>`;
+      })
       .join("");
   } catch (err) {
     container.innerHTML = '<div class="empty-state py-8">Failed to load reports.</div>';
@@ -83,8 +84,19 @@ async function viewReport(filename, clickedEl) {
       })
     );
 
-    const html = renderer.parse(md);
-    content.innerHTML = html;
+    // Front matter is metadata for listings, not part of the document.
+    const body = md.replace(/^---\r?\n[\s\S]*?\r?\n(?:---|\.\.\.)[ \t]*(?:\r?\n|$)/, "");
+    const html = renderer.parse(body);
+    const printable = `/api/reports/${encodeURIComponent(filename)}?format=html`;
+    content.innerHTML =
+      `<div class="flex justify-end gap-4"><button type="button" class="share-report text-subtext0 hover:text-blue text-xs inline-flex items-center gap-1 cursor-pointer" title="Share a read-only link to this report"><i data-lucide="share-2" class="w-4 h-4"></i>Share</button><a href="${printable}" target="_blank" rel="noopener" class="text-subtext0 hover:text-blue text-xs inline-flex items-center gap-1" title="Open a print-ready version"><i data-lucide="printer" class="w-4 h-4"></i>Printable</a></div>` +
+      html;
+    lucide.createIcons({ nodes: content.querySelectorAll("[data-lucide]") });
+    content.querySelector('a[href$="format=html"]').addEventListener("click", (e) => {
+      e.preventDefault();
+      openPrintable(printable);
+    });
+    content.querySelector(".share-report").addEventListener("click", () => createShare({ report: filename }));
 
     content.querySelectorAll("pre code.language-mermaid").forEach((block) => {
       const pre = block.parentElement;
@@ -99,3 +111,19 @@ async function viewReport(filename, clickedEl) {
     content.innerHTML = `<div class="empty-state">Failed to load report: ${escapeHtml(err.message)}</div>`;
   }
 }
+
+// openPrintable loads the HTML version through apiFetch so the API token
+// goes along, then shows it in a new tab.
+async function openPrintable(url) {
+  // Opened up front, while the click still counts as user-initiated.
+  const win = window.open("", "_blank");
+  try {
+    const res = await apiFetch(url);
+    if (!res.ok) throw new Error(res.statusText);
+    const blob = await res.blob();
+    win.location = URL.createObjectURL(blob);
+  } catch (err) {
+    if (win) win.close();
+    alert(`Failed to open printable report: ${err.message}`);
+  }
+}
@@ -1,6 +1,7 @@
 let allTouchpoints = [];
 let metadata = { categories: [], tags: [] };
 let editingId = null;
+let searchMatches = null;
 
 const CATEGORY_COLORS = [
   { bg: "#89b4fa", text: "#1e1e2e" },
@@ -35,10 +36,12 @@ function populateSelect(elementId, items, placeholder) {
 
 async function loadTouchpoints() {
   try {
-    [allTouchpoints, metadata] = await Promise.all([
+    const [page, md] = await Promise.all([
       api("/touchpoints"),
       api("/metadata"),
     ]);
+    allTouchpoints = page.touchpoints;
+    metadata = md;
   } catch (err) {
     console.error("Failed to load data:", err);
     allTouchpoints = [];
@@ -101,6 +104,7 @@ function getFilteredTouchpoints() {
   }
   const cat = getFilterCategory();
   const tags = getFilterTags();
+  if (searchMatches) tps = tps.filter((tp) => searchMatches.has(tp.id));
   if (cat) tps = tps.filter((tp) => tp.category === cat);
   if (tags.length) tps = tps.filter((tp) => tags.some((t) => (tp.tags || []).includes(t)));
   return tps;
@@ -266,6 +270,11 @@ document.getElementById("touchpoint-form").addEventListener("submit", async (e)
       .filter(Boolean),
     url: document.getElementById("tp-url").value.trim(),
   };
+  // Only send a date the user picked, so edits keep the original time of day.
+  const dateInput = document.getElementById("tp-date");
+  if (dateInput.value && dateInput.value !== dateInput.dataset.original) {
+    input.date = dateInput.value;
+  }
 
   try {
     if (editingId) {
@@ -289,6 +298,13 @@ document.getElementById("touchpoint-form").addEventListener("submit", async (e)
   }
 });
 
+function localDateString(iso) {
+  const d = new Date(iso);
+  if (isNaN(d)) return "";
+  const pad = (n) => String(n).padStart(2, "0");
+  return `${d.getFullYear()}-${pad(d.getMonth() + 1)}-${pad(d.getDate())}`;
+}
+
 function startEdit(id) {
   const tp = allTouchpoints.find((t) => t.id === id);
   if (!tp) return;
@@ -303,6 +319,9 @@ function startEdit(id) {
   setSelectedTags(tp.tags || []);
   document.getElementById("tp-people").value = (tp.people_involved || []).join(", ");
   document.getElementById("tp-url").value = tp.url || "";
+  const dateInput = document.getElementById("tp-date");
+  dateInput.value = localDateString(tp.date);
+  dateInput.dataset.original = dateInput.value;
 
   openModal();
 }
@@ -313,6 +332,7 @@ function cancelEdit() {
   document.getElementById("form-submit").textContent = "Add Touchpoint";
   document.getElementById("form-cancel").classList.add("hidden");
   document.getElementById("edit-id").value = "";
+  document.getElementById("tp-date").dataset.original = "";
   document.getElementById("touchpoint-form").reset();
   setSelectedTags([]);
 }
@@ -322,7 +342,7 @@ document.getElementById("form-cancel").addEventListener("click", () => {
 });
 
 async function deleteTouchpoint(id) {
-  if (!confirm("Delete this touchpoint?")) return;
+  if (!confirm("Move this touchpoint to the trash?")) return;
   try {
     await api(`/touchpoints/${id}`, { method: "DELETE" });
     await loadTouchpoints();
@@ -341,3 +361,42 @@ document.getElementById("date-range").addEventListener("change", () => {
   renderTouchpointList();
   if (typeof initDashboard === "function") initDashboard();
 });
+
+let searchTimer = null;
+document.getElementById("filter-search").addEventListener("input", (e) => {
+  clearTimeout(searchTimer);
+  searchTimer = setTimeout(async () => {
+    const q = e.target.value.trim();
+    if (!q) {
+      searchMatches = null;
+    } else {
+      try {
+        const page = await api(`/touchpoints?q=${encodeURIComponent(q)}`);
+        searchMatches = new Set(page.touchpoints.map((tp) => tp.id));
+      } catch (err) {
+        searchMatches = new Set();
+      }
+    }
+    renderTouchpointList();
+    if (typeof initDashboard === "function") initDashboard();
+  }, 250);
+});
+
+// The share link saves the dashboard's filters; a relative date range is
+// fixed to its start date at the time the link is made.
+document.getElementById("share-filtered").addEventListener("click", () => {
+  const filter = {};
+  const days = parseInt(document.getElementById("date-range").value, 10);
+  if (days > 0) {
+    const start = new Date();
+    start.setDate(start.getDate() - days);
+    filter.start_date = localDateString(start);
+  }
+  const cat = getFilterCategory();
+  if (cat) filter.categories = [cat];
+  const tags = getFilterTags();
+  if (tags.length) filter.tags = tags;
+  const q = document.getElementById("filter-search").value.trim();
+  if (q) filter.q = q;
+  createShare({ filter });
+});
@@ -2,23 +2,52 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/tanq16/ohara/internal/model"
 )
 
+// filterFromQuery reads the touchpoint filter parameters shared by listing
+// and export.
+func filterFromQuery(q url.Values) (model.TouchpointFilter, error) {
+	filter := model.TouchpointFilter{
+		Categories: q["category"],
+		Tags:       q["tag"],
+		StartDate:  q.Get("start_date"),
+		EndDate:    q.Get("end_date"),
+		Search:     q.Get("q"),
+		Query:      q.Get("query"),
+		Sort:       q.Get("sort"),
+		Order:      q.Get("order"),
+		Cursor:     q.Get("cursor"),
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return model.TouchpointFilter{}, fmt.Errorf("invalid limit: %s", limit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
 func (s *Server) listTouchpoints(w http.ResponseWriter, r *http.Request) {
-	category := r.URL.Query().Get("category")
-	tag := r.URL.Query().Get("tag")
-	startDate := r.URL.Query().Get("start_date")
+	filter, err := filterFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	tps, err := s.store.ListTouchpoints(category, tag, startDate)
+	page, err := s.storeFor(r).ListTouchpoints(filter)
 	if err != nil {
-		writeStoreError(w, err)
+		writeStoreError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, tps)
+	writeJSON(w, http.StatusOK, page)
 }
 
 func (s *Server) createTouchpoint(w http.ResponseWriter, r *http.Request) {
@@ -28,9 +57,9 @@ func (s *Server) createTouchpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tp, err := s.store.CreateTouchpoint(input)
+	tp, err := s.storeFor(r).CreateTouchpoint(input, requestAuthor(r))
 	if err != nil {
-		writeStoreError(w, err)
+		writeStoreError(w, r, err)
 		return
 	}
 
@@ -46,9 +75,9 @@ func (s *Server) updateTouchpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tp, err := s.store.UpdateTouchpoint(id, input)
+	tp, err := s.storeFor(r).UpdateTouchpoint(id, input, requestAuthor(r))
 	if err != nil {
-		writeStoreError(w, err)
+		writeStoreError(w, r, err)
 		return
 	}
 
@@ -58,11 +87,41 @@ func (s *Server) updateTouchpoint(w http.ResponseWriter, r *http.Request) {
 func (s *Server) deleteTouchpoint(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	err := s.store.DeleteTouchpoint(id)
+	err := s.storeFor(r).DeleteTouchpoint(id, requestAuthor(r))
 	if err != nil {
-		writeStoreError(w, err)
+		writeStoreError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (s *Server) touchpointHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	revs, err := s.storeFor(r).TouchpointHistory(id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revs)
+}
+
+func (s *Server) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil || rev < 1 {
+		writeError(w, http.StatusBadRequest, "invalid revision: "+r.PathValue("rev"))
+		return
+	}
+
+	tp, err := s.storeFor(r).RestoreRevision(id, rev, requestAuthor(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tp)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"time"
+)
+
+func (s *Server) listTrash(w http.ResponseWriter, r *http.Request) {
+	tps, err := s.storeFor(r).ListTrash()
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tps)
+}
+
+func (s *Server) restoreTouchpoint(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	tp, err := s.storeFor(r).RestoreTouchpoint(id, requestAuthor(r))
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tp)
+}
+
+func (s *Server) purgeTouchpoint(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := s.storeFor(r).PurgeTouchpoint(id); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) emptyTrash(w http.ResponseWriter, r *http.Request) {
+	n, err := s.storeFor(r).PurgeTrash(time.Now())
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+// purgeTrashLoop permanently removes touchpoints that have sat in the trash
+// longer than the configured retention, in every namespace, checking once at
+// startup and then hourly.
+func (s *Server) purgeTrashLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		stores, err := s.namespaces()
+		if err != nil {
+			serverLog().Error().Err(err).Msg("Failed to list namespaces for trash purge")
+		}
+		for user, st := range stores {
+			ns := cmp.Or(user, "the shared namespace")
+			n, err := st.PurgeTrash(time.Now().Add(-s.config.TrashRetention))
+			if err != nil {
+				serverLog().Error().Err(err).Str("namespace", ns).Msg("Failed to purge trash")
+			} else if n > 0 {
+				serverLog().Info().Int("count", n).Str("namespace", ns).Msg("Purged expired touchpoints from trash")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
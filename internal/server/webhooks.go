@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/tanq16/ohara/internal/model"
+	"github.com/tanq16/ohara/internal/webhook"
+)
+
+// writeWebhookError answers with the status matching a webhook error.
+func writeWebhookError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		writeError(w, http.StatusNotFound, "webhook "+id+": not found")
+	case errors.Is(err, webhook.ErrInvalid):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeStoreError(w, r, err)
+	}
+}
+
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	if s.config.Webhooks == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+	hooks, err := s.config.Webhooks.List(requestOwner(r))
+	if err != nil {
+		writeWebhookError(w, r, "", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+// createWebhook returns the new webhook with its signing secret, which is
+// not shown again.
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.config.Webhooks == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+	var req model.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	hook, err := s.config.Webhooks.Create(requestOwner(r), req, requestAuthor(r))
+	if err != nil {
+		writeWebhookError(w, r, "", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.config.Webhooks == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.config.Webhooks.Delete(requestOwner(r), id); err != nil {
+		writeWebhookError(w, r, id, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if s.config.Webhooks == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+	id := r.PathValue("id")
+	deliveries, err := s.config.Webhooks.Deliveries(requestOwner(r), id)
+	if err != nil {
+		writeWebhookError(w, r, id, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// testWebhook sends a ping event once and reports how the receiver
+// answered. A failed delivery is still a 200; its status says it failed.
+func (s *Server) testWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.config.Webhooks == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+	id := r.PathValue("id")
+	delivery, err := s.config.Webhooks.Test(requestOwner(r), id)
+	if err != nil {
+		writeWebhookError(w, r, id, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, delivery)
+}
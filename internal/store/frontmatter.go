@@ -0,0 +1,141 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+	"go.yaml.in/yaml/v3"
+)
+
+// splitFrontMatter separates a leading block fenced by --- lines from the
+// rest of a report. ok is false when the report has no front matter.
+func splitFrontMatter(content string) (front, body string, ok bool) {
+	first, rest, found := strings.Cut(content, "\n")
+	if !found || strings.TrimRight(first, " \r") != "---" {
+		return "", content, false
+	}
+	for offset := 0; offset < len(rest); {
+		line, _, _ := strings.Cut(rest[offset:], "\n")
+		if fence := strings.TrimRight(line, " \r"); fence == "---" || fence == "..." {
+			end := min(offset+len(line)+1, len(rest))
+			return rest[:offset], rest[end:], true
+		}
+		offset += len(line) + 1
+	}
+	return "", content, false
+}
+
+func parseFrontMatter(content string) (model.ReportFrontMatter, error) {
+	var fm model.ReportFrontMatter
+	front, _, ok := splitFrontMatter(content)
+	if !ok {
+		return fm, nil
+	}
+	if err := yaml.Unmarshal([]byte(front), &fm); err != nil {
+		return model.ReportFrontMatter{}, validationErr(fmt.Sprintf("invalid front matter: %v", err))
+	}
+	if p := fm.Period; p != nil {
+		for _, v := range []string{p.Start, p.End} {
+			if v == "" {
+				continue
+			}
+			if _, _, err := parseDatePeriod(v); err != nil {
+				return model.ReportFrontMatter{}, validationErr(fmt.Sprintf("invalid front matter period %q: %v", v, err))
+			}
+		}
+	}
+	return fm, nil
+}
+
+// validateReportContent rejects reports whose front matter can't be read,
+// so listings never have to guess.
+func validateReportContent(content string) error {
+	_, err := parseFrontMatter(content)
+	return err
+}
+
+// ParseReport splits a report into its front matter and Markdown body. The
+// title falls back to the first top-level heading and then the filename.
+// Unreadable front matter, say from a file edited on disk, is treated as
+// absent.
+func ParseReport(filename, content string) (model.ReportFrontMatter, string) {
+	fm, _ := parseFrontMatter(content)
+	_, body, _ := splitFrontMatter(content)
+	if fm.Title == "" {
+		for line := range strings.Lines(body) {
+			if title, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+				fm.Title = strings.TrimSpace(title)
+				break
+			}
+		}
+	}
+	if fm.Title == "" {
+		fm.Title = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	return fm, body
+}
+
+func reportInfo(filename, content string, created, modified time.Time) model.ReportInfo {
+	fm, _ := ParseReport(filename, content)
+	return model.ReportInfo{
+		Filename:          filename,
+		ReportFrontMatter: fm,
+		Size:              int64(len(content)),
+		CreatedAt:         created.UTC().Format(time.RFC3339),
+		ModifiedAt:        modified.UTC().Format(time.RFC3339),
+	}
+}
+
+type reportCriteria struct {
+	statuses   []string
+	start, end time.Time
+}
+
+func parseReportFilter(f model.ReportFilter) (reportCriteria, error) {
+	c := reportCriteria{statuses: nonEmpty(f.Statuses)}
+	if f.StartDate != "" {
+		from, _, err := parseDatePeriod(f.StartDate)
+		if err != nil {
+			return reportCriteria{}, validationErr(fmt.Sprintf("invalid start_date format: %s", f.StartDate))
+		}
+		c.start = from
+	}
+	if f.EndDate != "" {
+		_, to, err := parseDatePeriod(f.EndDate)
+		if err != nil {
+			return reportCriteria{}, validationErr(fmt.Sprintf("invalid end_date format: %s", f.EndDate))
+		}
+		c.end = to
+	}
+	return c, nil
+}
+
+// match reports whether a report passes the filter. A report without a
+// period never matches a date filter; an open end of its period is
+// unbounded.
+func (c reportCriteria) match(r model.ReportInfo) bool {
+	if len(c.statuses) > 0 && !slices.ContainsFunc(c.statuses, func(s string) bool { return strings.EqualFold(s, r.Status) }) {
+		return false
+	}
+	if c.start.IsZero() && c.end.IsZero() {
+		return true
+	}
+	if r.Period == nil || (r.Period.Start == "" && r.Period.End == "") {
+		return false
+	}
+	if r.Period.Start != "" && !c.end.IsZero() {
+		if from, _, _ := parseDatePeriod(r.Period.Start); !from.Before(c.end) {
+			return false
+		}
+	}
+	if r.Period.End != "" && !c.start.IsZero() {
+		if _, to, _ := parseDatePeriod(r.Period.End); !to.After(c.start) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		name, filename, content string
+		title, body, status     string
+		period                  *model.ReportPeriod
+	}{
+		{
+			name:     "front matter",
+			filename: "q1.md",
+			content:  "---\ntitle: Q1 review\nstatus: draft\nperiod:\n  start: 2025-01\n  end: 2025-03\n---\n# Heading\nBody\n",
+			title:    "Q1 review", body: "# Heading\nBody\n", status: "draft",
+			period: &model.ReportPeriod{Start: "2025-01", End: "2025-03"},
+		},
+		{
+			name:     "dots close the block",
+			filename: "q1.md",
+			content:  "---\ntitle: Q1 review\n...\nBody\n",
+			title:    "Q1 review", body: "Body\n",
+		},
+		{
+			name:     "windows line endings",
+			filename: "q1.md",
+			content:  "---\r\ntitle: Q1 review\r\n---\r\nBody\r\n",
+			title:    "Q1 review", body: "Body\r\n",
+		},
+		{
+			name:     "closing fence at the end",
+			filename: "q1.md",
+			content:  "---\ntitle: Q1 review\n---",
+			title:    "Q1 review", body: "",
+		},
+		{
+			name:     "heading fallback",
+			filename: "q1.md",
+			content:  "---\nstatus: final\n---\nIntro\n## Not this\n# Quarter one \nBody\n",
+			title:    "Quarter one", body: "Intro\n## Not this\n# Quarter one \nBody\n", status: "final",
+		},
+		{
+			name:     "filename fallback",
+			filename: "2025-q1.report.md",
+			content:  "Just text\n",
+			title:    "2025-q1.report", body: "Just text\n",
+		},
+		{
+			name:     "unclosed block is body",
+			filename: "q1.md",
+			content:  "---\ntitle: Q1 review\n",
+			title:    "q1", body: "---\ntitle: Q1 review\n",
+		},
+		{
+			name:     "invalid yaml is ignored",
+			filename: "q1.md",
+			content:  "---\ntitle: [unclosed\n---\n# From heading\n",
+			title:    "From heading", body: "# From heading\n",
+		},
+		{
+			name:     "invalid period is ignored",
+			filename: "q1.md",
+			content:  "---\ntitle: Q1 review\nperiod:\n  start: last spring\n---\n",
+			title:    "q1", body: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body := ParseReport(tt.filename, tt.content)
+			if fm.Title != tt.title || body != tt.body || fm.Status != tt.status {
+				t.Errorf("got title %q, status %q, body %q; want %q, %q, %q", fm.Title, fm.Status, body, tt.title, tt.status, tt.body)
+			}
+			if (fm.Period == nil) != (tt.period == nil) || (fm.Period != nil && *fm.Period != *tt.period) {
+				t.Errorf("period %+v, want %+v", fm.Period, tt.period)
+			}
+		})
+	}
+}
+
+func TestValidateReportContent(t *testing.T) {
+	valid := []string{
+		"No front matter",
+		"---\ntitle: Q1\nperiod:\n  start: 2025\n  end: 2025-03-31T12:00:00Z\n---\n",
+		"---\nperiod:\n  end: 2025-06\n---\n",
+	}
+	for _, content := range valid {
+		if err := validateReportContent(content); err != nil {
+			t.Errorf("validateReportContent(%q): %v", content, err)
+		}
+	}
+	invalid := []string{
+		"---\ntitle: [unclosed\n---\n",
+		"---\nperiod:\n  start: 2025-13\n---\n",
+		"---\nperiod:\n  end: yesterday\n---\n",
+	}
+	for _, content := range invalid {
+		if err := validateReportContent(content); !errors.Is(err, ErrValidation) {
+			t.Errorf("validateReportContent(%q): %v, want ErrValidation", content, err)
+		}
+	}
+}
+
+func TestReportCriteria(t *testing.T) {
+	reports := map[string]model.ReportInfo{
+		"q1":       {ReportFrontMatter: model.ReportFrontMatter{Status: "final", Period: &model.ReportPeriod{Start: "2025-01", End: "2025-03"}}},
+		"year":     {ReportFrontMatter: model.ReportFrontMatter{Status: "Draft", Period: &model.ReportPeriod{Start: "2024", End: "2024"}}},
+		"since":    {ReportFrontMatter: model.ReportFrontMatter{Status: "draft", Period: &model.ReportPeriod{Start: "2025-03-15"}}},
+		"until":    {ReportFrontMatter: model.ReportFrontMatter{Period: &model.ReportPeriod{End: "2024-06-30T23:59:59Z"}}},
+		"undated":  {ReportFrontMatter: model.ReportFrontMatter{Status: "final"}},
+		"emptyper": {ReportFrontMatter: model.ReportFrontMatter{Status: "final", Period: &model.ReportPeriod{}}},
+	}
+	tests := []struct {
+		name   string
+		filter model.ReportFilter
+		want   []string
+	}{
+		{"no filter", model.ReportFilter{}, []string{"emptyper", "q1", "since", "undated", "until", "year"}},
+		{"status ignores case", model.ReportFilter{Statuses: []string{"DRAFT"}}, []string{"since", "year"}},
+		{"several statuses", model.ReportFilter{Statuses: []string{"draft", "final", ""}}, []string{"emptyper", "q1", "since", "undated", "year"}},
+		// A filter year covers the whole year, and so does a period's.
+		{"year", model.ReportFilter{StartDate: "2025", EndDate: "2025"}, []string{"q1", "since"}},
+		{"month touching the end", model.ReportFilter{StartDate: "2025-03", EndDate: "2025-03"}, []string{"q1", "since"}},
+		{"day after the end", model.ReportFilter{StartDate: "2025-04-01"}, []string{"since"}},
+		{"day before the start", model.ReportFilter{EndDate: "2024-12-31"}, []string{"until", "year"}},
+		{"last second of the open end", model.ReportFilter{StartDate: "2024-06-30T23:59:59Z", EndDate: "2024-07"}, []string{"until", "year"}},
+		{"second after the open end", model.ReportFilter{StartDate: "2024-07-01T00:00:00Z", EndDate: "2024-07"}, []string{"year"}},
+		{"dates and status", model.ReportFilter{StartDate: "2025", Statuses: []string{"final"}}, []string{"q1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseReportFilter(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for name, r := range reports {
+				if c.match(r) {
+					got = append(got, name)
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, f := range []model.ReportFilter{{StartDate: "March"}, {EndDate: "2025-02-30"}} {
+		if _, err := parseReportFilter(f); !errors.Is(err, ErrValidation) {
+			t.Errorf("parseReportFilter(%+v): %v, want ErrValidation", f, err)
+		}
+	}
+}
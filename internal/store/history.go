@@ -0,0 +1,240 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+const (
+	actionCreate  = "create"
+	actionUpdate  = "update"
+	actionDelete  = "delete"
+	actionRestore = "restore"
+)
+
+// diffTouchpoints lists the user-visible fields that differ between two
+// versions of a touchpoint.
+func diffTouchpoints(before, after model.Touchpoint) []model.FieldChange {
+	changes := []model.FieldChange{}
+	add := func(field string, old, new any) {
+		changes = append(changes, model.FieldChange{Field: field, Old: old, New: new})
+	}
+
+	if before.Date != after.Date {
+		add("date", before.Date, after.Date)
+	}
+	if before.Description != after.Description {
+		add("description", before.Description, after.Description)
+	}
+	if before.Category != after.Category {
+		add("category", before.Category, after.Category)
+	}
+	if !slices.Equal(before.Tags, after.Tags) {
+		add("tags", before.Tags, after.Tags)
+	}
+	if !slices.Equal(before.PeopleInvolved, after.PeopleInvolved) {
+		add("people_involved", before.PeopleInvolved, after.PeopleInvolved)
+	}
+	if before.URL != after.URL {
+		add("url", before.URL, after.URL)
+	}
+	return changes
+}
+
+// newRevision records a change from before to after. Deletes snapshot the
+// last live state so it can be restored later.
+func newRevision(rev int, action, author string, before, after model.Touchpoint) model.Revision {
+	r := model.Revision{
+		Rev:       rev,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Author:    author,
+		Action:    action,
+		Snapshot:  after,
+	}
+	switch action {
+	case actionCreate:
+		r.Changes = diffTouchpoints(model.Touchpoint{}, after)
+	case actionDelete:
+		r.Changes = []model.FieldChange{}
+		r.Snapshot = before
+	default:
+		r.Changes = diffTouchpoints(before, after)
+	}
+	r.TouchpointID = r.Snapshot.ID
+	return r
+}
+
+// baselineRevision stands in for the creation of touchpoints that predate
+// revision history, so their original state can still be restored.
+func baselineRevision(tp model.Touchpoint) model.Revision {
+	return model.Revision{
+		Rev:          1,
+		TouchpointID: tp.ID,
+		Timestamp:    tp.Date,
+		Author:       "unknown",
+		Action:       actionCreate,
+		Changes:      diffTouchpoints(model.Touchpoint{}, tp),
+		Snapshot:     tp,
+	}
+}
+
+func findRevision(revs []model.Revision, id string, rev int) (model.Revision, error) {
+	for _, r := range revs {
+		if r.Rev == rev {
+			return r, nil
+		}
+	}
+	return model.Revision{}, notFoundErr("revision", fmt.Sprintf("%d of touchpoint %s", rev, id))
+}
+
+func (s *Store) loadHistory() (map[string][]model.Revision, error) {
+	data, err := os.ReadFile(s.historyPath())
+	if os.IsNotExist(err) {
+		return map[string][]model.Revision{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	history := map[string][]model.Revision{}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// recordRevision appends a revision for a change about to be saved.
+// Callers must hold tpMu.
+func (s *Store) recordRevision(action, author string, before, after model.Touchpoint) error {
+	id := after.ID
+	if action == actionDelete {
+		id = before.ID
+	}
+
+	revs := slices.Clone(s.history[id])
+	if len(revs) == 0 && action != actionCreate {
+		revs = append(revs, baselineRevision(before))
+	}
+	revs = append(revs, newRevision(len(revs)+1, action, author, before, after))
+
+	history := maps.Clone(s.history)
+	history[id] = revs
+	return s.saveHistory(history)
+}
+
+// saveChange saves tps along with the revision recording the change from
+// before to after. History is written first and put back if the
+// touchpoints cannot be saved, so the two files never disagree. Callers
+// must hold tpMu.
+func (s *Store) saveChange(tps []model.Touchpoint, action, author string, before, after model.Touchpoint) error {
+	prev := s.history
+	if err := s.recordRevision(action, author, before, after); err != nil {
+		return err
+	}
+	if err := s.saveTouchpoints(tps); err != nil {
+		if rbErr := s.saveHistory(prev); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// dropHistory forgets the revisions of purged touchpoints. Callers must
+// hold tpMu.
+func (s *Store) dropHistory(ids []string) error {
+	history := maps.Clone(s.history)
+	for _, id := range ids {
+		delete(history, id)
+	}
+	return s.saveHistory(history)
+}
+
+func (s *Store) saveHistory(history map[string][]model.Revision) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicWrite(s.historyPath(), data); err != nil {
+		return err
+	}
+	s.history = history
+	s.historyFile = statFile(s.historyPath())
+	return nil
+}
+
+func (s *Store) TouchpointHistory(id string) ([]model.Revision, error) {
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	s.tpMu.RLock()
+	defer s.tpMu.RUnlock()
+
+	if revs := s.history[id]; len(revs) > 0 {
+		return slices.Clone(revs), nil
+	}
+	if i, ok := s.tps.byID[id]; ok {
+		return []model.Revision{baselineRevision(s.tps.all[i])}, nil
+	}
+	return nil, notFoundErr("touchpoint", id)
+}
+
+func (s *Store) RestoreRevision(id string, rev int, author string) (model.Touchpoint, error) {
+	s.tpMu.Lock()
+	defer s.tpMu.Unlock()
+	if err := s.reloadChanged(); err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	revs := s.history[id]
+	i, live := s.tps.byID[id]
+	if live && s.tps.all[i].DeletedAt != "" {
+		return model.Touchpoint{}, validationErr("touchpoint " + id + " is in the trash; restore it from there first")
+	}
+	if len(revs) == 0 && live {
+		revs = []model.Revision{baselineRevision(s.tps.all[i])}
+	}
+	target, err := findRevision(revs, id, rev)
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	restored := target.Snapshot
+	s.mdMu.RLock()
+	err = s.validateInput(inputFromTouchpoint(restored))
+	s.mdMu.RUnlock()
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	var before model.Touchpoint
+	tps := s.touchpoints()
+	if live {
+		before = tps[i]
+		tps[i] = restored
+	} else {
+		tps = append(tps, restored)
+	}
+	if err := s.saveChange(tps, actionRestore, author, before, restored); err != nil {
+		return model.Touchpoint{}, err
+	}
+	s.onChange.notify(model.EventTouchpointUpdated, restored)
+	return restored, nil
+}
+
+func inputFromTouchpoint(tp model.Touchpoint) model.TouchpointInput {
+	return model.TouchpointInput{
+		Date:           tp.Date,
+		Description:    tp.Description,
+		Category:       tp.Category,
+		Tags:           tp.Tags,
+		PeopleInvolved: tp.PeopleInvolved,
+		URL:            tp.URL,
+	}
+}
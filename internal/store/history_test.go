@@ -0,0 +1,212 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// blockSave makes the next save of a data file fail, by putting a
+// directory where atomicWrite writes its temporary file. The returned func
+// lets saves through again.
+func blockSave(t *testing.T, s *Store, name string) func() {
+	t.Helper()
+	tmp := filepath.Join(s.dataDir, name+".tmp")
+	if err := os.Mkdir(tmp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return func() { os.Remove(tmp) }
+}
+
+// newJSONStore opens a JSON store in a fresh directory and records the
+// types of the events it announces.
+func newJSONStore(t *testing.T) (*Store, *[]string) {
+	t.Helper()
+	var events []string
+	s, err := New(Config{DataDir: t.TempDir(), OnChange: func(typ string, data any) {
+		events = append(events, typ)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, &events
+}
+
+func TestDiffTouchpoints(t *testing.T) {
+	base := model.Touchpoint{
+		Date:           "2025-01-15T12:00:00Z",
+		Description:    "Fixed login bug",
+		Category:       "Bug Fix",
+		Tags:           []string{"backend", "security"},
+		PeopleInvolved: []string{"Alice"},
+		URL:            "https://git.example.com/pr/1",
+	}
+	tests := []struct {
+		name   string
+		change func(tp *model.Touchpoint)
+		want   []string
+	}{
+		{"nothing", func(tp *model.Touchpoint) {}, []string{}},
+		{"date", func(tp *model.Touchpoint) { tp.Date = "2025-01-16T12:00:00Z" }, []string{"date"}},
+		{"description and url", func(tp *model.Touchpoint) { tp.Description = "Fixed it"; tp.URL = "" }, []string{"description", "url"}},
+		{"category", func(tp *model.Touchpoint) { tp.Category = "Code Review" }, []string{"category"}},
+		{"tag order", func(tp *model.Touchpoint) { tp.Tags = []string{"security", "backend"} }, []string{"tags"}},
+		{"people", func(tp *model.Touchpoint) { tp.PeopleInvolved = nil }, []string{"people_involved"}},
+		// IDs and trash state are not user-visible fields.
+		{"bookkeeping", func(tp *model.Touchpoint) { tp.ID = "other"; tp.DeletedAt = "2025-02-01T00:00:00Z" }, []string{}},
+	}
+	for _, tt := range tests {
+		after := base
+		after.Tags = slices.Clone(base.Tags)
+		tt.change(&after)
+		changes := diffTouchpoints(base, after)
+		fields := []string{}
+		for _, c := range changes {
+			fields = append(fields, c.Field)
+		}
+		if !slices.Equal(fields, tt.want) {
+			t.Errorf("%s: changed %v, want %v", tt.name, fields, tt.want)
+		}
+	}
+
+	changes := diffTouchpoints(base, model.Touchpoint{Description: "New", Category: base.Category, Tags: base.Tags, PeopleInvolved: base.PeopleInvolved, URL: base.URL, Date: base.Date})
+	if len(changes) != 1 || changes[0].Old != "Fixed login bug" || changes[0].New != "New" {
+		t.Errorf("description change %+v", changes)
+	}
+}
+
+func TestHistoryAndRestore(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend) {
+		created, err := s.CreateTouchpoint(listFixtures[0], "ada")
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := s.UpdateTouchpoint(created.ID, model.TouchpointInput{
+			Description:    "Fixed login timeout bug for good",
+			Category:       created.Category,
+			Tags:           []string{"backend"},
+			PeopleInvolved: created.PeopleInvolved,
+			URL:            created.URL,
+		}, "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Date != created.Date {
+			t.Errorf("update without a date moved it from %s to %s", created.Date, updated.Date)
+		}
+
+		restored, err := s.RestoreRevision(created.ID, 1, "carol")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.Description != created.Description || !slices.Equal(restored.Tags, created.Tags) {
+			t.Errorf("restored %+v, want %+v", restored, created)
+		}
+
+		revs, err := s.TouchpointHistory(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []struct {
+			action, author string
+			fields         []string
+		}{
+			{actionCreate, "ada", []string{"date", "description", "category", "tags", "people_involved", "url"}},
+			{actionUpdate, "bob", []string{"description", "tags"}},
+			{actionRestore, "carol", []string{"description", "tags"}},
+		}
+		if len(revs) != len(want) {
+			t.Fatalf("%d revisions, want %d: %+v", len(revs), len(want), revs)
+		}
+		for i, w := range want {
+			r := revs[i]
+			fields := []string{}
+			for _, c := range r.Changes {
+				fields = append(fields, c.Field)
+			}
+			if r.Rev != i+1 || r.Action != w.action || r.Author != w.author || !slices.Equal(fields, w.fields) || r.TouchpointID != created.ID {
+				t.Errorf("revision %d: %+v, want %s by %s changing %v", i+1, r, w.action, w.author, w.fields)
+			}
+		}
+		if revs[1].Changes[0].Old != created.Description || revs[1].Changes[0].New != updated.Description {
+			t.Errorf("update recorded %+v", revs[1].Changes[0])
+		}
+		if revs[1].Snapshot.Description != updated.Description {
+			t.Errorf("update snapshot %+v", revs[1].Snapshot)
+		}
+
+		if _, err := s.RestoreRevision(created.ID, 9, "ada"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("restoring a missing revision: %v, want ErrNotFound", err)
+		}
+		if _, err := s.TouchpointHistory("missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("history of a missing touchpoint: %v, want ErrNotFound", err)
+		}
+
+		// Deleting snapshots the last live state.
+		if err := s.DeleteTouchpoint(created.ID, "dave"); err != nil {
+			t.Fatal(err)
+		}
+		revs, err = s.TouchpointHistory(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := revs[len(revs)-1]; last.Action != actionDelete || last.Snapshot.Description != created.Description || len(last.Changes) != 0 {
+			t.Errorf("delete recorded %+v", last)
+		}
+	})
+}
+
+// TestSaveChangeRollsBack makes one of the two files a change is saved to
+// unwritable and checks neither keeps the change.
+func TestSaveChangeRollsBack(t *testing.T) {
+	for _, file := range []string{"history.json", "touchpoints.json"} {
+		t.Run(file, func(t *testing.T) {
+			s, events := newJSONStore(t)
+			tp, err := s.CreateTouchpoint(listFixtures[0], "ada")
+			if err != nil {
+				t.Fatal(err)
+			}
+			*events = nil
+
+			unblock := blockSave(t, s, file)
+			input := inputFromTouchpoint(tp)
+			input.Description = "Changed"
+			if _, err := s.UpdateTouchpoint(tp.ID, input, "bob"); err == nil {
+				t.Fatal("update succeeded with an unwritable " + file)
+			}
+			if err := s.DeleteTouchpoint(tp.ID, "bob"); err == nil {
+				t.Fatal("delete succeeded with an unwritable " + file)
+			}
+			unblock()
+
+			// Both in memory and on disk, nothing changed.
+			reopened, err := New(Config{DataDir: s.dataDir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, st := range map[string]*Store{"store": s, "reopened": reopened} {
+				page, err := st.ListTouchpoints(model.TouchpointFilter{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Touchpoints) != 1 || page.Touchpoints[0].Description != tp.Description {
+					t.Errorf("%s lists %+v", name, page.Touchpoints)
+				}
+				revs, err := st.TouchpointHistory(tp.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(revs) != 1 {
+					t.Errorf("%s has %d revisions, want 1", name, len(revs))
+				}
+			}
+			if len(*events) != 0 {
+				t.Errorf("events %v announced for failed changes", *events)
+			}
+		})
+	}
+}
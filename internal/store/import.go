@@ -0,0 +1,149 @@
+package store
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// prepareImport runs every row through the same sanitizing and validation
+// as CreateTouchpoint and returns the touchpoints that passed. With
+// CreateMissing, unknown categories and tags are appended to md and listed
+// in the report.
+func prepareImport(p *bluemonday.Policy, md *model.Metadata, rows []model.ImportRow, opts model.ImportOptions, tolerance time.Duration) ([]model.Touchpoint, model.ImportReport) {
+	report := model.ImportReport{
+		DryRun:            opts.DryRun,
+		Total:             len(rows),
+		Failed:            []model.ImportFailure{},
+		CreatedCategories: []string{},
+		CreatedTags:       []string{},
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	var tps []model.Touchpoint
+	for _, row := range rows {
+		if row.Error != "" {
+			report.Failed = append(report.Failed, model.ImportFailure{Row: row.Row, Error: row.Error})
+			continue
+		}
+
+		input := row.Input
+		sanitizeInput(p, &input)
+		err := normalizeDate(&input, tolerance)
+		if err == nil && opts.CreateMissing && input.Description != "" {
+			addMissingMetadata(md, input, &report)
+		}
+		if err == nil {
+			err = validateAgainst(*md, input)
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, model.ImportFailure{Row: row.Row, Error: err.Error()})
+			continue
+		}
+
+		if input.Date == "" {
+			input.Date = now
+		}
+		tps = append(tps, model.Touchpoint{
+			ID:             uuid.New().String(),
+			Date:           input.Date,
+			Description:    input.Description,
+			Category:       input.Category,
+			Tags:           input.Tags,
+			PeopleInvolved: input.PeopleInvolved,
+			URL:            input.URL,
+		})
+	}
+	report.Imported = len(tps)
+	return tps, report
+}
+
+func addMissingMetadata(md *model.Metadata, input model.TouchpointInput, report *model.ImportReport) {
+	if input.Category != "" && !contains(md.Categories, input.Category) {
+		md.Categories = append(md.Categories, input.Category)
+		report.CreatedCategories = append(report.CreatedCategories, input.Category)
+	}
+	for _, tag := range input.Tags {
+		if tag != "" && !contains(md.Tags, tag) {
+			md.Tags = append(md.Tags, tag)
+			report.CreatedTags = append(report.CreatedTags, tag)
+		}
+	}
+}
+
+func (s *Store) ImportTouchpoints(rows []model.ImportRow, opts model.ImportOptions, author string) (model.ImportReport, error) {
+	s.tpMu.Lock()
+	defer s.tpMu.Unlock()
+	s.mdMu.Lock()
+	defer s.mdMu.Unlock()
+	if err := s.reloadChanged(); err != nil {
+		return model.ImportReport{}, err
+	}
+
+	md, err := s.loadMetadata()
+	if err != nil {
+		return model.ImportReport{}, err
+	}
+	prevMD := model.Metadata{Categories: slices.Clone(md.Categories), Tags: slices.Clone(md.Tags)}
+	imported, report := prepareImport(s.sanitizer, &md, rows, opts, s.tolerance)
+	if opts.DryRun {
+		return report, nil
+	}
+	if err := s.saveImport(md, prevMD, imported, report, author); err != nil {
+		return model.ImportReport{}, err
+	}
+
+	if len(report.CreatedCategories) > 0 || len(report.CreatedTags) > 0 {
+		s.onChange.notify(model.EventMetadataUpdated, md)
+	}
+	if len(imported) > 0 {
+		s.onChange.notify(model.EventTouchpointsImported, report)
+	}
+	return report, nil
+}
+
+// saveImport saves the metadata an import created, then the history of the
+// imported touchpoints, then the touchpoints themselves, the same order as
+// saveChange. If a later file cannot be saved, the earlier ones are put
+// back so nothing of a failed import is left behind. Callers must hold
+// tpMu and mdMu.
+func (s *Store) saveImport(md, prevMD model.Metadata, imported []model.Touchpoint, report model.ImportReport, author string) error {
+	var undo []func() error
+	rollback := func(err error) error {
+		errs := []error{err}
+		for i := len(undo) - 1; i >= 0; i-- {
+			errs = append(errs, undo[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	if len(report.CreatedCategories) > 0 || len(report.CreatedTags) > 0 {
+		if err := s.saveMetadata(md); err != nil {
+			return err
+		}
+		undo = append(undo, func() error { return s.saveMetadata(prevMD) })
+	}
+	if len(imported) == 0 {
+		return nil
+	}
+
+	prevHistory := s.history
+	history := maps.Clone(s.history)
+	for _, tp := range imported {
+		history[tp.ID] = []model.Revision{newRevision(1, actionCreate, author, model.Touchpoint{}, tp)}
+	}
+	if err := s.saveHistory(history); err != nil {
+		return rollback(err)
+	}
+	undo = append(undo, func() error { return s.saveHistory(prevHistory) })
+
+	if err := s.saveTouchpoints(append(s.touchpoints(), imported...)); err != nil {
+		return rollback(err)
+	}
+	return nil
+}
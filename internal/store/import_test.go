@@ -0,0 +1,79 @@
+package store
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func TestImportRollsBackFailedSave(t *testing.T) {
+	rows := []model.ImportRow{
+		{Row: 1, Input: model.TouchpointInput{Description: "first", Category: "Research", Tags: []string{"backend"}}},
+		{Row: 2, Input: model.TouchpointInput{Description: "second", Category: "Bug Fix", Tags: []string{"ml"}}},
+	}
+	opts := model.ImportOptions{CreateMissing: true}
+
+	for _, file := range []string{"history.json", "touchpoints.json"} {
+		t.Run(file, func(t *testing.T) {
+			s, events := newJSONStore(t)
+			before, err := s.GetMetadata()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			unblock := blockSave(t, s, file)
+			if _, err := s.ImportTouchpoints(rows, opts, "ada"); err == nil {
+				t.Fatal("import succeeded with an unwritable " + file)
+			}
+			unblock()
+
+			md, err := s.GetMetadata()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(md.Categories, before.Categories) || !slices.Equal(md.Tags, before.Tags) {
+				t.Errorf("metadata kept created names: %+v", md)
+			}
+			page, err := s.ListTouchpoints(model.TouchpointFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Touchpoints) != 0 {
+				t.Errorf("%d touchpoints kept", len(page.Touchpoints))
+			}
+			if len(s.history) != 0 {
+				t.Errorf("history kept %d touchpoints", len(s.history))
+			}
+			if len(*events) != 0 {
+				t.Errorf("events %v announced for a failed import", *events)
+			}
+
+			// Reopening reads back the same, rolled back files.
+			reopened, err := New(Config{DataDir: s.dataDir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(reopened.touchpoints()); n != 0 || len(reopened.history) != 0 {
+				t.Errorf("reopened store has %d touchpoints and %d histories", n, len(reopened.history))
+			}
+
+			report, err := s.ImportTouchpoints(rows, opts, "ada")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Imported != 2 {
+				t.Errorf("imported %d, want 2", report.Imported)
+			}
+			want := []string{model.EventMetadataUpdated, model.EventTouchpointsImported}
+			if !slices.Equal(*events, want) {
+				t.Errorf("events %v, want %v", *events, want)
+			}
+			for _, tp := range s.touchpoints() {
+				if revs := s.history[tp.ID]; len(revs) != 1 || revs[0].Author != "ada" {
+					t.Errorf("history of %s: %+v", tp.ID, revs)
+				}
+			}
+		})
+	}
+}
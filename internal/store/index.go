@@ -0,0 +1,172 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// touchpointIndex is the parsed, in-memory copy of touchpoints.json. It is
+// rebuilt from scratch on every save, so reads never touch the file.
+type touchpointIndex struct {
+	all  []model.Touchpoint
+	byID map[string]int
+	// Every index below covers live entries only; trashed ones are in trash.
+	live       []int
+	trash      []int
+	byCategory map[string][]int
+	byTag      map[string][]int
+	// byDate holds positions of entries with a parseable date, ordered by date.
+	byDate []int
+	dates  []time.Time
+	search *searchIndex
+}
+
+func newTouchpointIndex(tps []model.Touchpoint) *touchpointIndex {
+	idx := &touchpointIndex{
+		all:        tps,
+		byID:       make(map[string]int, len(tps)),
+		byCategory: make(map[string][]int),
+		byTag:      make(map[string][]int),
+		byDate:     make([]int, 0, len(tps)),
+		dates:      make([]time.Time, len(tps)),
+	}
+
+	for i, tp := range tps {
+		idx.byID[tp.ID] = i
+		if tp.DeletedAt != "" {
+			idx.trash = append(idx.trash, i)
+			continue
+		}
+		idx.live = append(idx.live, i)
+		idx.byCategory[tp.Category] = append(idx.byCategory[tp.Category], i)
+		for _, tag := range tp.Tags {
+			positions := idx.byTag[tag]
+			// Guard against a tag repeated on the same touchpoint.
+			if n := len(positions); n > 0 && positions[n-1] == i {
+				continue
+			}
+			idx.byTag[tag] = append(positions, i)
+		}
+		if t, err := time.Parse(time.RFC3339, tp.Date); err == nil {
+			idx.dates[i] = t
+			idx.byDate = append(idx.byDate, i)
+		}
+	}
+
+	sort.SliceStable(idx.byDate, func(a, b int) bool {
+		return idx.dates[idx.byDate[a]].Before(idx.dates[idx.byDate[b]])
+	})
+	idx.search = newSearchIndex(tps, idx.live)
+	return idx
+}
+
+// since returns positions, in file order, of entries dated at or after start.
+func (idx *touchpointIndex) since(start time.Time) []int {
+	from := sort.Search(len(idx.byDate), func(i int) bool {
+		return !idx.dates[idx.byDate[i]].Before(start)
+	})
+	positions := slices.Clone(idx.byDate[from:])
+	slices.Sort(positions)
+	return positions
+}
+
+// union merges position lists into one sorted list without duplicates.
+func union(lists ...[]int) []int {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	var out []int
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// filter returns matching touchpoints, starting from the narrowest index
+// available for the given criteria. Results are in file order, by
+// descending relevance when searching, or by the requested sort.
+func (idx *touchpointIndex) filter(c criteria) []model.Touchpoint {
+	var candidates []int
+	narrowed := false
+	narrow := func(positions []int) {
+		if !narrowed || len(positions) < len(candidates) {
+			candidates = positions
+			narrowed = true
+		}
+	}
+
+	var scores map[int]float64
+	if len(c.search) > 0 {
+		scores = idx.search.search(c.search)
+		hits := make([]int, 0, len(scores))
+		for i := range scores {
+			hits = append(hits, i)
+		}
+		slices.Sort(hits)
+		narrow(hits)
+	}
+	if len(c.categories) > 0 {
+		lists := make([][]int, len(c.categories))
+		for i, cat := range c.categories {
+			lists[i] = idx.byCategory[cat]
+		}
+		narrow(union(lists...))
+	}
+	if len(c.tags) > 0 {
+		lists := make([][]int, len(c.tags))
+		for i, tag := range c.tags {
+			lists[i] = idx.byTag[tag]
+		}
+		narrow(union(lists...))
+	}
+	if !c.start.IsZero() && (!narrowed || len(candidates) > 0) {
+		narrow(idx.since(c.start))
+	}
+
+	if !narrowed {
+		candidates = idx.live
+	}
+
+	matched := make([]int, 0, len(candidates))
+	for _, i := range candidates {
+		tp := idx.all[i]
+		if scores != nil {
+			if _, ok := scores[i]; !ok {
+				continue
+			}
+		}
+		if len(c.categories) > 0 && !contains(c.categories, tp.Category) {
+			continue
+		}
+		if len(c.tags) > 0 && !slices.ContainsFunc(c.tags, func(t string) bool { return contains(tp.Tags, t) }) {
+			continue
+		}
+		if !c.start.IsZero() || !c.end.IsZero() {
+			d := idx.dates[i]
+			if d.IsZero() || d.Before(c.start) || (!c.end.IsZero() && !d.Before(c.end)) {
+				continue
+			}
+		}
+		if c.query != nil && !c.query.match(tp) {
+			continue
+		}
+		matched = append(matched, i)
+	}
+
+	if scores != nil {
+		sort.SliceStable(matched, func(a, b int) bool {
+			return scores[matched[a]] > scores[matched[b]]
+		})
+	}
+
+	result := make([]model.Touchpoint, 0, len(matched))
+	for _, i := range matched {
+		result = append(result, idx.all[i])
+	}
+	sortTouchpoints(result, c)
+	return result
+}
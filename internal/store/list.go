@@ -0,0 +1,188 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+const maxPageSize = 1000
+
+// criteria is a parsed model.TouchpointFilter.
+type criteria struct {
+	categories []string
+	tags       []string
+	// start and end bound dates as [start, end); zero means unbounded.
+	start  time.Time
+	end    time.Time
+	search []searchTerm
+	query  queryNode
+	sort   string
+	desc   bool
+	limit  int
+	cursor *pageCursor
+}
+
+// pageCursor marks the last touchpoint of a page. The sort key lets a
+// sorted listing resume even if that touchpoint has since been deleted.
+type pageCursor struct {
+	ID  string `json:"id"`
+	Key string `json:"k,omitempty"`
+}
+
+func parseFilter(f model.TouchpointFilter) (criteria, error) {
+	c := criteria{
+		categories: nonEmpty(f.Categories),
+		tags:       nonEmpty(f.Tags),
+		sort:       f.Sort,
+		limit:      f.Limit,
+	}
+
+	if f.StartDate != "" {
+		from, _, err := parseDatePeriod(f.StartDate)
+		if err != nil {
+			return criteria{}, validationErr(fmt.Sprintf("invalid start_date format: %s", f.StartDate))
+		}
+		c.start = from
+	}
+	if f.EndDate != "" {
+		_, to, err := parseDatePeriod(f.EndDate)
+		if err != nil {
+			return criteria{}, validationErr(fmt.Sprintf("invalid end_date format: %s", f.EndDate))
+		}
+		c.end = to
+	}
+	if !c.start.IsZero() && !c.end.IsZero() && !c.start.Before(c.end) {
+		return criteria{}, validationErr("start_date must be before end_date")
+	}
+
+	if f.Search != "" {
+		terms, err := parseSearch(f.Search)
+		if err != nil {
+			return criteria{}, err
+		}
+		c.search = terms
+	}
+	if f.Query != "" {
+		node, err := parseQuery(f.Query)
+		if err != nil {
+			return criteria{}, err
+		}
+		c.query = node
+	}
+
+	switch f.Sort {
+	case "", "date", "category":
+	default:
+		return criteria{}, validationErr(fmt.Sprintf("invalid sort: %s (expected date or category)", f.Sort))
+	}
+	switch f.Order {
+	case "", "asc":
+	case "desc":
+		c.desc = true
+	default:
+		return criteria{}, validationErr(fmt.Sprintf("invalid order: %s (expected asc or desc)", f.Order))
+	}
+
+	if f.Limit < 0 || f.Limit > maxPageSize {
+		return criteria{}, validationErr(fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+	}
+	if f.Cursor != "" {
+		cur, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return criteria{}, err
+		}
+		c.cursor = cur
+	}
+	return c, nil
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func encodeCursor(cur pageCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, validationErr("invalid cursor")
+	}
+	var cur pageCursor
+	if err := json.Unmarshal(data, &cur); err != nil || cur.ID == "" {
+		return nil, validationErr("invalid cursor")
+	}
+	return &cur, nil
+}
+
+// sortKey orders touchpoints for the requested sort. Dates are stored as
+// UTC RFC3339, so they compare correctly as strings.
+func sortKey(tp model.Touchpoint, sort string) string {
+	switch sort {
+	case "date":
+		return tp.Date
+	case "category":
+		return tp.Category + "\x00" + tp.Date
+	}
+	return ""
+}
+
+// sortTouchpoints applies the requested sort, keeping the existing order
+// among equal keys.
+func sortTouchpoints(tps []model.Touchpoint, c criteria) {
+	if c.sort == "" {
+		return
+	}
+	slices.SortStableFunc(tps, func(a, b model.Touchpoint) int {
+		cmp := strings.Compare(sortKey(a, c.sort), sortKey(b, c.sort))
+		if c.desc {
+			return -cmp
+		}
+		return cmp
+	})
+}
+
+// paginate cuts the page described by the cursor and limit out of a fully
+// filtered and ordered result.
+func paginate(tps []model.Touchpoint, c criteria) (model.TouchpointPage, error) {
+	start := 0
+	if c.cursor != nil {
+		i := slices.IndexFunc(tps, func(tp model.Touchpoint) bool { return tp.ID == c.cursor.ID })
+		switch {
+		case i >= 0:
+			start = i + 1
+		case c.sort != "":
+			start = slices.IndexFunc(tps, func(tp model.Touchpoint) bool {
+				cmp := strings.Compare(sortKey(tp, c.sort), c.cursor.Key)
+				return (!c.desc && cmp > 0) || (c.desc && cmp < 0)
+			})
+			if start < 0 {
+				start = len(tps)
+			}
+		default:
+			return model.TouchpointPage{}, validationErr("cursor no longer matches any touchpoint; restart from the first page")
+		}
+	}
+
+	page := model.TouchpointPage{Touchpoints: tps[start:]}
+	if c.limit > 0 && len(page.Touchpoints) > c.limit {
+		page.Touchpoints = page.Touchpoints[:c.limit]
+		last := page.Touchpoints[c.limit-1]
+		page.NextCursor = encodeCursor(pageCursor{ID: last.ID, Key: sortKey(last, c.sort)})
+	}
+	return page, nil
+}
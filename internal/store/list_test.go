@@ -0,0 +1,106 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, cur := range []pageCursor{
+		{ID: "a1"},
+		{ID: "b2", Key: "2025-01-15T12:00:00Z"},
+		{ID: "c3", Key: "Bug Fix\x002025-01-15T12:00:00Z"},
+	} {
+		got, err := decodeCursor(encodeCursor(cur))
+		if err != nil {
+			t.Errorf("decode(encode(%+v)): %v", cur, err)
+			continue
+		}
+		if *got != cur {
+			t.Errorf("decode(encode(%+v)) = %+v", cur, *got)
+		}
+	}
+	// Not base64, not JSON, and a cursor without an ID.
+	for _, s := range []string{"not a cursor!", "bm90IGpzb24", encodeCursor(pageCursor{Key: "2025"})} {
+		if _, err := decodeCursor(s); !errors.Is(err, ErrValidation) {
+			t.Errorf("decodeCursor(%q): %v, want ErrValidation", s, err)
+		}
+	}
+}
+
+func TestListRejectsBadFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter model.TouchpointFilter
+		want   string
+	}{
+		{"limit above the maximum", model.TouchpointFilter{Limit: maxPageSize + 1}, "limit must be between 1 and 1000"},
+		{"negative limit", model.TouchpointFilter{Limit: -1}, "limit must be between 1 and 1000"},
+		{"garbled cursor", model.TouchpointFilter{Cursor: "%%%"}, "invalid cursor"},
+		{"unknown sort", model.TouchpointFilter{Sort: "title"}, "invalid sort"},
+		{"unknown order", model.TouchpointFilter{Sort: "date", Order: "up"}, "invalid order"},
+		{"start after end", model.TouchpointFilter{StartDate: "2025-03-01", EndDate: "2025-02-01"}, "start_date must be before end_date"},
+		{"bad start date", model.TouchpointFilter{StartDate: "yesterday"}, "invalid start_date"},
+	}
+	forEachBackend(t, func(t *testing.T, s backend) {
+		for _, tt := range tests {
+			_, err := s.ListTouchpoints(tt.filter)
+			if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: %v, want a validation error about %q", tt.name, err, tt.want)
+			}
+		}
+		if _, err := s.ListTouchpoints(model.TouchpointFilter{Limit: maxPageSize}); err != nil {
+			t.Errorf("limit %d: %v", maxPageSize, err)
+		}
+	})
+}
+
+// TestCursorAfterDelete deletes the touchpoint a cursor points at before
+// the next page is read.
+func TestCursorAfterDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend) {
+		tps := createFixtures(t, s)
+		byDesc := map[string]string{}
+		for _, tp := range tps {
+			byDesc[tp.Description] = tp.ID
+		}
+
+		// Creation order has no key to resume from, so the cursor is stale.
+		first, err := s.ListTouchpoints(model.TouchpointFilter{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteTouchpoint(first.Touchpoints[1].ID, "ada"); err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.ListTouchpoints(model.TouchpointFilter{Limit: 2, Cursor: first.NextCursor})
+		if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "cursor no longer matches") {
+			t.Errorf("stale cursor: %v, want a validation error", err)
+		}
+
+		// A sorted listing resumes after the deleted touchpoint's key.
+		f := model.TouchpointFilter{Sort: "date", Order: "desc", Limit: 2}
+		page, err := s.ListTouchpoints(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := descriptions(page.Touchpoints); !slices.Equal(got, []string{wroteDocs, pairedWith}) {
+			t.Fatalf("first page %q", got)
+		}
+		if err := s.DeleteTouchpoint(byDesc[pairedWith], "ada"); err != nil {
+			t.Fatal(err)
+		}
+		f.Cursor = page.NextCursor
+		page, err = s.ListTouchpoints(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := descriptions(page.Touchpoints); !slices.Equal(got, []string{fixLogin, designed}) || page.NextCursor != "" {
+			t.Errorf("page after the deleted cursor %q, next %q", got, page.NextCursor)
+		}
+	})
+}
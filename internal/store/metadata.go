@@ -27,6 +27,15 @@ func (s *Store) saveMetadata(md model.Metadata) error {
 	return atomicWrite(s.metadataPath(), data)
 }
 
+// saveMetadataChange saves md after an edit and announces it.
+func (s *Store) saveMetadataChange(md model.Metadata) error {
+	if err := s.saveMetadata(md); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventMetadataUpdated, md)
+	return nil
+}
+
 func (s *Store) GetMetadata() (model.Metadata, error) {
 	s.mdMu.RLock()
 	defer s.mdMu.RUnlock()
@@ -51,7 +60,7 @@ func (s *Store) AddCategory(name string) error {
 	}
 
 	md.Categories = append(md.Categories, name)
-	return s.saveMetadata(md)
+	return s.saveMetadataChange(md)
 }
 
 func (s *Store) RemoveCategory(name string) error {
@@ -79,7 +88,7 @@ func (s *Store) RemoveCategory(name string) error {
 	}
 
 	md.Categories = append(md.Categories[:idx], md.Categories[idx+1:]...)
-	return s.saveMetadata(md)
+	return s.saveMetadataChange(md)
 }
 
 func (s *Store) AddTag(name string) error {
@@ -100,7 +109,7 @@ func (s *Store) AddTag(name string) error {
 	}
 
 	md.Tags = append(md.Tags, name)
-	return s.saveMetadata(md)
+	return s.saveMetadataChange(md)
 }
 
 func (s *Store) RemoveTag(name string) error {
@@ -128,5 +137,5 @@ func (s *Store) RemoveTag(name string) error {
 	}
 
 	md.Tags = append(md.Tags[:idx], md.Tags[idx+1:]...)
-	return s.saveMetadata(md)
+	return s.saveMetadataChange(md)
 }
@@ -0,0 +1,375 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// A query expression combines field comparisons with AND, OR, NOT and
+// parentheses, e.g.
+//
+//	category:"Bug Fix" AND (tag:backend OR tag:security) AND date>=2025-01-01 AND person:alice
+//
+// Adjacent terms are ANDed. A bare word matches touchpoints whose
+// description, people or URL contain it.
+//
+// Fields:
+//
+//	category, tag        exact match, ignoring case (: = !=)
+//	person               substring of any person involved (: = !=)
+//	text, url            substring of the description or URL (: = !=)
+//	date                 YYYY, YYYY-MM, YYYY-MM-DD or RFC3339 (: = != > >= < <=)
+//
+// A date comparison covers the whole period written, so date:2025-03 is
+// all of March and date>2025-03 starts in April. RFC3339 timestamps such as
+// date>=2025-01-02T03:04:05Z need no quotes despite their colons.
+//
+// Errors give the position of the problem in characters, counting from 1.
+type queryNode interface {
+	match(tp model.Touchpoint) bool
+}
+
+type andNode struct{ left, right queryNode }
+type orNode struct{ left, right queryNode }
+type notNode struct{ inner queryNode }
+
+func (n andNode) match(tp model.Touchpoint) bool { return n.left.match(tp) && n.right.match(tp) }
+func (n orNode) match(tp model.Touchpoint) bool  { return n.left.match(tp) || n.right.match(tp) }
+func (n notNode) match(tp model.Touchpoint) bool { return !n.inner.match(tp) }
+
+type fieldNode struct {
+	field  string
+	negate bool
+	value  string
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (n fieldNode) match(tp model.Touchpoint) bool {
+	var ok bool
+	switch n.field {
+	case "category":
+		ok = strings.EqualFold(tp.Category, n.value)
+	case "tag":
+		for _, t := range tp.Tags {
+			if strings.EqualFold(t, n.value) {
+				ok = true
+				break
+			}
+		}
+	case "person":
+		for _, p := range tp.PeopleInvolved {
+			if containsFold(p, n.value) {
+				ok = true
+				break
+			}
+		}
+	case "text":
+		ok = containsFold(tp.Description, n.value)
+	case "url":
+		ok = containsFold(tp.URL, n.value)
+	case "":
+		ok = containsFold(tp.Description, n.value) || containsFold(tp.URL, n.value)
+		for _, p := range tp.PeopleInvolved {
+			ok = ok || containsFold(p, n.value)
+		}
+	}
+	return ok != n.negate
+}
+
+// dateNode compares a touchpoint's date against the period [from, to).
+type dateNode struct {
+	op       string
+	from, to time.Time
+}
+
+func (n dateNode) match(tp model.Touchpoint) bool {
+	t, err := time.Parse(time.RFC3339, tp.Date)
+	if err != nil {
+		return false
+	}
+	switch n.op {
+	case ">":
+		return !t.Before(n.to)
+	case ">=":
+		return !t.Before(n.from)
+	case "<":
+		return t.Before(n.from)
+	case "<=":
+		return t.Before(n.to)
+	case "!=":
+		return t.Before(n.from) || !t.Before(n.to)
+	default:
+		return !t.Before(n.from) && t.Before(n.to)
+	}
+}
+
+// parseDatePeriod reads a date literal as the period it spans, in UTC.
+func parseDatePeriod(value string) (time.Time, time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, t.Add(time.Second), nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.Parse("2006-01", value); err == nil {
+		return t, t.AddDate(0, 1, 0), nil
+	}
+	if t, err := time.Parse("2006", value); err == nil {
+		return t, t.AddDate(1, 0, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("expected YYYY, YYYY-MM, YYYY-MM-DD or RFC3339")
+}
+
+var queryFieldAliases = map[string]string{
+	"category":    "category",
+	"cat":         "category",
+	"tag":         "tag",
+	"person":      "person",
+	"people":      "person",
+	"text":        "text",
+	"description": "text",
+	"url":         "url",
+	"date":        "date",
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	// pos counts characters, not bytes, from the start of the query.
+	pos int
+}
+
+// rfc3339Literal matches a timestamp at the start of a word, so its colons
+// are not read as field separators.
+var rfc3339Literal = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+
+func isQueryDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`():=!<>"`, r)
+}
+
+func lexQuery(src string) ([]queryToken, error) {
+	var tokens []queryToken
+	at := func(i int) int { return utf8.RuneCountInString(src[:i]) }
+	i := 0
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, queryToken{tokLParen, "(", at(i)})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{tokRParen, ")", at(i)})
+			i++
+		case r == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, queryErr(at(i), "unterminated quoted string")
+			}
+			tokens = append(tokens, queryToken{tokString, src[i+1 : i+1+end], at(i)})
+			i += end + 2
+		case strings.ContainsRune(":=!<>", r):
+			op := string(r)
+			if i+1 < len(src) && src[i+1] == '=' && r != ':' && r != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, queryErr(at(i), "expected !=")
+			}
+			tokens = append(tokens, queryToken{tokOp, op, at(i)})
+			i += len(op)
+		case rfc3339Literal.MatchString(src[i:]):
+			ts := rfc3339Literal.FindString(src[i:])
+			tokens = append(tokens, queryToken{tokWord, ts, at(i)})
+			i += len(ts)
+		default:
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if isQueryDelimiter(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, queryToken{tokWord, src[start:i], at(start)})
+		}
+	}
+	return append(tokens, queryToken{tokEOF, "", at(len(src))}), nil
+}
+
+func queryErr(pos int, msg string) error {
+	return validationErr(fmt.Sprintf("invalid query at character %d: %s", pos+1, msg))
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// parseQuery parses a query expression into a matcher.
+func parseQuery(src string) (queryNode, error) {
+	tokens, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, queryErr(0, "query is empty")
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, queryErr(tok.pos, fmt.Sprintf("unexpected %q", tok.text))
+	}
+	return node, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) isKeyword(kw string) bool {
+	tok := p.peek()
+	return tok.kind == tokWord && strings.EqualFold(tok.text, kw)
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.isKeyword("AND") {
+			p.next()
+		} else if tok := p.peek(); tok.kind == tokEOF || tok.kind == tokRParen || p.isKeyword("OR") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, queryErr(closing.pos, "expected )")
+		}
+		return node, nil
+	case tokString:
+		return fieldNode{value: tok.text}, nil
+	case tokWord:
+		if p.peek().kind != tokOp {
+			if isReservedWord(tok.text) {
+				return nil, queryErr(tok.pos, fmt.Sprintf("expected a term after %s", strings.ToUpper(tok.text)))
+			}
+			return fieldNode{value: tok.text}, nil
+		}
+		return p.parseComparison(tok)
+	case tokEOF:
+		return nil, queryErr(tok.pos, "unexpected end of query")
+	default:
+		return nil, queryErr(tok.pos, fmt.Sprintf("unexpected %q", tok.text))
+	}
+}
+
+func isReservedWord(w string) bool {
+	return strings.EqualFold(w, "AND") || strings.EqualFold(w, "OR") || strings.EqualFold(w, "NOT")
+}
+
+func (p *queryParser) parseComparison(fieldTok queryToken) (queryNode, error) {
+	field, ok := queryFieldAliases[strings.ToLower(fieldTok.text)]
+	if !ok {
+		return nil, queryErr(fieldTok.pos, fmt.Sprintf("unknown field %q (expected category, tag, person, text, url or date)", fieldTok.text))
+	}
+
+	opTok := p.next()
+	valueTok := p.next()
+	if valueTok.kind != tokWord && valueTok.kind != tokString {
+		return nil, queryErr(valueTok.pos, fmt.Sprintf("expected a value after %s%s", fieldTok.text, opTok.text))
+	}
+
+	if field == "date" {
+		from, to, err := parseDatePeriod(valueTok.text)
+		if err != nil {
+			return nil, queryErr(valueTok.pos, fmt.Sprintf("invalid date %q: %v", valueTok.text, err))
+		}
+		return dateNode{op: opTok.text, from: from, to: to}, nil
+	}
+
+	switch opTok.text {
+	case ":", "=":
+		return fieldNode{field: field, value: valueTok.text}, nil
+	case "!=":
+		return fieldNode{field: field, negate: true, value: valueTok.text}, nil
+	default:
+		return nil, queryErr(opTok.pos, fmt.Sprintf("operator %s only applies to date", opTok.text))
+	}
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// queryFixtures are matched in order by the query tests, named by ID.
+var queryFixtures = []model.Touchpoint{
+	{ID: "a", Date: "2025-03-10T12:00:00Z", Description: "Fixed login bug", Category: "Bug Fix", Tags: []string{"backend"}, PeopleInvolved: []string{"Alice Smith"}, URL: "https://git.example.com/1"},
+	{ID: "b", Date: "2025-04-01T00:00:00Z", Description: "Designed search", Category: "Technical Design", Tags: []string{"frontend", "security"}, PeopleInvolved: []string{"Bob"}},
+	{ID: "c", Date: "2024-12-31T23:59:59Z", Description: "Reviewed PR", Category: "Code Review", Tags: []string{"backend", "security"}, PeopleInvolved: []string{"alice"}, URL: "https://git.example.com/pr/3"},
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`category:"Bug Fix"`, []string{"a"}},
+		{`cat="bug fix"`, []string{"a"}},
+		{`tag:security`, []string{"b", "c"}},
+		{`tag!=security`, []string{"a"}},
+		{`person:alice`, []string{"a", "c"}},
+		{`people:SMITH`, []string{"a"}},
+		{`text:review`, []string{"c"}},
+		{`description!=search`, []string{"a", "c"}},
+		{`url:pr/3`, []string{"c"}},
+		{`bob`, []string{"b"}},
+		{`"login bug"`, []string{"a"}},
+		{`"git.example.com"`, []string{"a", "c"}},
+
+		// AND binds tighter than OR, and NOT tighter than both.
+		{`tag:frontend OR tag:backend AND category:"Code Review"`, []string{"b", "c"}},
+		{`(tag:frontend OR tag:backend) AND category:"Code Review"`, []string{"c"}},
+		{`NOT tag:security OR person:bob`, []string{"a", "b"}},
+		{`NOT (tag:security OR person:bob)`, []string{"a"}},
+		{`NOT NOT tag:frontend`, []string{"b"}},
+		{`tag:backend smith`, []string{"a"}},
+		{`tag:security and not person:bob`, []string{"c"}},
+		{`((tag:backend))`, []string{"a", "c"}},
+
+		// Dates cover the whole period written.
+		{`date:2024`, []string{"c"}},
+		{`date!=2025`, []string{"c"}},
+		{`date:2025-03`, []string{"a"}},
+		{`date>2025-03`, []string{"b"}},
+		{`date>=2025-03`, []string{"a", "b"}},
+		{`date<2025`, []string{"c"}},
+		{`date<=2025-03-10`, []string{"a", "c"}},
+		{`date=2025-04-01`, []string{"b"}},
+		{`date>=2025-03-10T12:00:00Z`, []string{"a", "b"}},
+		{`date>2025-03-10T12:00:00Z`, []string{"b"}},
+		{`date:2025-03-10T13:00:00+01:00`, []string{"a"}},
+		{`date<2025-03-10T12:00:00.5Z`, []string{"a", "c"}},
+		{`date>=2025-01-01 AND date<2025-04-01T00:00:00Z`, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := parseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, tp := range queryFixtures {
+				if node.match(tp) {
+					got = append(got, tp.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{``, `character 1: query is empty`},
+		{`   `, `character 1: query is empty`},
+		{`tag:`, `character 5: expected a value after tag:`},
+		{`(tag:backend`, `character 13: expected )`},
+		{`colour:red`, `character 1: unknown field "colour"`},
+		{`tag>backend`, `character 4: operator > only applies to date`},
+		{`date:2025-13`, `character 6: invalid date "2025-13"`},
+		{`date>=2025-01-02T03:04Z`, `character 7: invalid date "2025-01-02T03"`},
+		{`tag:a "open`, `character 7: unterminated quoted string`},
+		{`tag:a !b`, `character 7: expected !=`},
+		{`tag:a AND`, `character 10: unexpected end of query`},
+		{`NOT`, `character 4: unexpected end of query`},
+		{`tag:a )`, `character 7: unexpected ")"`},
+		{`tag:a OR AND tag:b`, `character 10: expected a term after AND`},
+		// Positions count characters, not bytes.
+		{`café AND )`, `character 10: unexpected ")"`},
+		{`person:"Zoë" date:x`, `character 19: invalid date "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := parseQuery(tt.query)
+			if err == nil {
+				t.Fatal("parsed without error")
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("error %v is not ErrValidation", err)
+			}
+			if !strings.Contains(err.Error(), "invalid query at "+tt.want) {
+				t.Errorf("error %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,40 +1,106 @@
 package store
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
 )
 
 var validFilename = regexp.MustCompile(`^[\w][\w\-]*\.md$`)
 
-func (s *Store) validateReportFilename(filename string) error {
+func validateReportFilename(filename string) error {
 	if !validFilename.MatchString(filename) {
 		return fmt.Errorf("report filename %s (must match alphanumeric/hyphens ending in .md): %w", filename, ErrInvalidFilename)
 	}
 	return nil
 }
 
-func (s *Store) ListReports() ([]string, error) {
+// loadReportIndex reads when each report was created, which the files
+// themselves can't portably record. Reports missing from it, such as files
+// copied in by hand, fall back to their modification time.
+func (s *Store) loadReportIndex() (map[string]string, error) {
+	data, err := os.ReadFile(s.reportIndexPath())
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]string{}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// updateReportIndex applies fn to the created-time index and saves it.
+// Callers must hold rpMu.
+func (s *Store) updateReportIndex(fn func(index map[string]string)) error {
+	index, err := s.loadReportIndex()
+	if err != nil {
+		return err
+	}
+	fn(index)
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWrite(s.reportIndexPath(), data)
+}
+
+func (s *Store) ListReports(f model.ReportFilter) ([]model.ReportInfo, error) {
+	c, err := parseReportFilter(f)
+	if err != nil {
+		return nil, err
+	}
+
+	s.rpMu.Lock()
+	defer s.rpMu.Unlock()
+
 	entries, err := os.ReadDir(s.reportsDir())
 	if err != nil {
 		return nil, err
 	}
+	index, err := s.loadReportIndex()
+	if err != nil {
+		return nil, err
+	}
 
-	names := make([]string, 0, len(entries))
+	reports := make([]model.ReportInfo, 0, len(entries))
 	for _, e := range entries {
-		if !e.IsDir() && filepath.Ext(e.Name()) == ".md" {
-			names = append(names, e.Name())
+		if e.IsDir() || validateReportFilename(e.Name()) != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(filepath.Join(s.reportsDir(), e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		created := info.ModTime()
+		if t, err := time.Parse(time.RFC3339, index[e.Name()]); err == nil {
+			created = t
+		}
+		if r := reportInfo(e.Name(), string(content), created, info.ModTime()); c.match(r) {
+			reports = append(reports, r)
 		}
 	}
-	sort.Sort(sort.Reverse(sort.StringSlice(names)))
-	return names, nil
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Filename > reports[j].Filename })
+	return reports, nil
 }
 
 func (s *Store) GetReport(filename string) (string, error) {
-	if err := s.validateReportFilename(filename); err != nil {
+	if err := validateReportFilename(filename); err != nil {
 		return "", err
 	}
 
@@ -48,15 +114,124 @@ func (s *Store) GetReport(filename string) (string, error) {
 	return string(data), nil
 }
 
+// ReportVersion identifies a revision of a report's content, for
+// optimistic concurrency on updates.
+func ReportVersion(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:16])
+}
+
+// checkReportVersion compares the version a caller last saw against the
+// current content. An empty version skips the check.
+func checkReportVersion(filename, current, version string) error {
+	if version != "" && version != ReportVersion(current) {
+		return conflictErr("report", filename)
+	}
+	return nil
+}
+
 func (s *Store) CreateReport(filename, content string) error {
-	if err := s.validateReportFilename(filename); err != nil {
+	if err := validateReportFilename(filename); err != nil {
 		return err
 	}
+	if err := validateReportContent(content); err != nil {
+		return err
+	}
+
+	s.rpMu.Lock()
+	defer s.rpMu.Unlock()
 
 	path := filepath.Join(s.reportsDir(), filename)
 	if _, err := os.Stat(path); err == nil {
 		return alreadyExistsErr("report", filename)
 	}
 
-	return os.WriteFile(path, []byte(content), 0644)
+	if err := atomicWrite(path, []byte(content)); err != nil {
+		return err
+	}
+	if err := s.updateReportIndex(func(index map[string]string) {
+		index[filename] = time.Now().UTC().Format(time.RFC3339)
+	}); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventReportPublished, reportChange(filename, content))
+	return nil
+}
+
+// UpdateReport replaces a report's content. If version is set, the update
+// only goes through while the stored content still has that version.
+func (s *Store) UpdateReport(filename, content, version string) error {
+	if err := validateReportContent(content); err != nil {
+		return err
+	}
+
+	s.rpMu.Lock()
+	defer s.rpMu.Unlock()
+
+	current, err := s.GetReport(filename)
+	if err != nil {
+		return err
+	}
+	if err := checkReportVersion(filename, current, version); err != nil {
+		return err
+	}
+	if err := atomicWrite(filepath.Join(s.reportsDir(), filename), []byte(content)); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventReportUpdated, reportChange(filename, content))
+	return nil
+}
+
+func (s *Store) RenameReport(filename, newFilename string) error {
+	if err := validateReportFilename(newFilename); err != nil {
+		return err
+	}
+
+	s.rpMu.Lock()
+	defer s.rpMu.Unlock()
+
+	if _, err := s.GetReport(filename); err != nil {
+		return err
+	}
+	newPath := filepath.Join(s.reportsDir(), newFilename)
+	if _, err := os.Stat(newPath); err == nil {
+		return alreadyExistsErr("report", newFilename)
+	}
+	if err := os.Rename(filepath.Join(s.reportsDir(), filename), newPath); err != nil {
+		return err
+	}
+	if err := s.updateReportIndex(func(index map[string]string) {
+		if created, ok := index[filename]; ok {
+			index[newFilename] = created
+			delete(index, filename)
+		}
+	}); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventReportRenamed, map[string]string{"filename": filename, "new_filename": newFilename})
+	return nil
+}
+
+func (s *Store) DeleteReport(filename string) error {
+	if err := validateReportFilename(filename); err != nil {
+		return err
+	}
+
+	s.rpMu.Lock()
+	defer s.rpMu.Unlock()
+
+	err := os.Remove(filepath.Join(s.reportsDir(), filename))
+	if os.IsNotExist(err) {
+		return notFoundErr("report", filename)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.updateReportIndex(func(index map[string]string) {
+		delete(index, filename)
+	}); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventReportDeleted, map[string]string{"filename": filename})
+	return nil
 }
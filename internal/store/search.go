@@ -0,0 +1,237 @@
+package store
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// searchTerm is one clause of a full-text query: a single word, a quoted
+// phrase, or a word ending in * that matches any term with that prefix.
+// Every clause must match for a touchpoint to be returned.
+type searchTerm struct {
+	tokens []string
+	prefix bool
+}
+
+// tokenize lowercases text and splits it into runs of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func parseSearch(q string) ([]searchTerm, error) {
+	var terms []searchTerm
+	add := func(chunk string, quoted bool) {
+		prefix := !quoted && strings.HasSuffix(chunk, "*")
+		tokens := tokenize(chunk)
+		if len(tokens) > 0 {
+			terms = append(terms, searchTerm{tokens: tokens, prefix: prefix})
+		}
+	}
+
+	rest := q
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, validationErr("unterminated phrase in search query")
+			}
+			add(rest[1:end+1], true)
+			rest = rest[end+2:]
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(rest)
+		}
+		add(rest[:end], false)
+		rest = rest[end:]
+	}
+
+	if len(terms) == 0 {
+		return nil, validationErr("search query has no searchable words")
+	}
+	return terms, nil
+}
+
+// Field weights for ranking: a match on a person's name says more about a
+// touchpoint than the same word buried in its description or URL.
+const (
+	weightDescription = 1.0
+	weightPeople      = 2.0
+	weightURL         = 0.5
+)
+
+type posting struct {
+	positions []int
+	weight    float64
+}
+
+// searchIndex is an inverted index over the description, people and URL of
+// live touchpoints, keyed by their position in touchpointIndex.all.
+type searchIndex struct {
+	postings map[string]map[int]*posting
+	// terms is sorted so prefix queries can binary search it.
+	terms   []string
+	lengths map[int]int
+	avgLen  float64
+}
+
+func newSearchIndex(tps []model.Touchpoint, live []int) *searchIndex {
+	si := &searchIndex{
+		postings: make(map[string]map[int]*posting),
+		lengths:  make(map[int]int, len(live)),
+	}
+
+	total := 0
+	for _, doc := range live {
+		tp := tps[doc]
+		pos := 0
+		addField := func(text string, weight float64) {
+			for _, tok := range tokenize(text) {
+				docs := si.postings[tok]
+				if docs == nil {
+					docs = make(map[int]*posting)
+					si.postings[tok] = docs
+				}
+				p := docs[doc]
+				if p == nil {
+					p = &posting{}
+					docs[doc] = p
+				}
+				p.positions = append(p.positions, pos)
+				p.weight += weight
+				pos++
+			}
+			// Leave a gap so phrases never match across field boundaries.
+			pos++
+		}
+
+		addField(tp.Description, weightDescription)
+		for _, person := range tp.PeopleInvolved {
+			addField(person, weightPeople)
+		}
+		addField(tp.URL, weightURL)
+
+		si.lengths[doc] = pos
+		total += pos
+	}
+
+	si.terms = make([]string, 0, len(si.postings))
+	for term := range si.postings {
+		si.terms = append(si.terms, term)
+	}
+	sort.Strings(si.terms)
+	if len(live) > 0 {
+		si.avgLen = float64(total) / float64(len(live))
+	}
+	return si
+}
+
+// expand returns the index terms a clause token stands for.
+func (si *searchIndex) expand(token string, prefix bool) []string {
+	if !prefix {
+		if _, ok := si.postings[token]; ok {
+			return []string{token}
+		}
+		return nil
+	}
+	from := sort.SearchStrings(si.terms, token)
+	to := from
+	for to < len(si.terms) && strings.HasPrefix(si.terms[to], token) {
+		to++
+	}
+	return si.terms[from:to]
+}
+
+// match returns the weighted frequency of a clause in each matching doc.
+func (si *searchIndex) match(term searchTerm) map[int]float64 {
+	last := len(term.tokens) - 1
+	hits := make(map[int]float64)
+
+	if last == 0 {
+		for _, t := range si.expand(term.tokens[0], term.prefix) {
+			for doc, p := range si.postings[t] {
+				hits[doc] += p.weight
+			}
+		}
+		return hits
+	}
+
+	// Phrase: every token must appear at consecutive positions. The final
+	// token may be a prefix.
+	lists := make([][]string, len(term.tokens))
+	for i, tok := range term.tokens {
+		lists[i] = si.expand(tok, term.prefix && i == last)
+		if len(lists[i]) == 0 {
+			return hits
+		}
+	}
+
+	for doc, first := range si.postings[lists[0][0]] {
+		count := 0
+		for _, start := range first.positions {
+			if si.phraseAt(doc, start, lists) {
+				count++
+			}
+		}
+		if count > 0 {
+			hits[doc] = float64(count) * first.weight / float64(len(first.positions))
+		}
+	}
+	return hits
+}
+
+func (si *searchIndex) phraseAt(doc, start int, lists [][]string) bool {
+	for i := 1; i < len(lists); i++ {
+		found := false
+		for _, t := range lists[i] {
+			if p := si.postings[t][doc]; p != nil && slices.Contains(p.positions, start+i) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// search scores every doc that matches all terms using BM25.
+func (si *searchIndex) search(terms []searchTerm) map[int]float64 {
+	const k1, b = 1.2, 0.75
+	n := float64(len(si.lengths))
+
+	var scores map[int]float64
+	for _, term := range terms {
+		hits := si.match(term)
+		idf := math.Log(1 + (n-float64(len(hits))+0.5)/(float64(len(hits))+0.5))
+
+		next := make(map[int]float64, len(hits))
+		for doc, tf := range hits {
+			if scores != nil {
+				if _, ok := scores[doc]; !ok {
+					continue
+				}
+			}
+			norm := k1 * (1 - b + b*float64(si.lengths[doc])/si.avgLen)
+			next[doc] = scores[doc] + idf*tf*(k1+1)/(tf+norm)
+		}
+		scores = next
+		if len(scores) == 0 {
+			break
+		}
+	}
+	return scores
+}
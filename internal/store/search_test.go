@@ -0,0 +1,97 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []searchTerm
+	}{
+		{"Cache", []searchTerm{{tokens: []string{"cache"}}}},
+		{"warm* layer", []searchTerm{{tokens: []string{"warm"}, prefix: true}, {tokens: []string{"layer"}}}},
+		{`"Cache warmup" tuning`, []searchTerm{{tokens: []string{"cache", "warmup"}}, {tokens: []string{"tuning"}}}},
+		// A star inside quotes is punctuation, not a prefix.
+		{`"warm*"`, []searchTerm{{tokens: []string{"warm"}}}},
+		{"pr-42", []searchTerm{{tokens: []string{"pr", "42"}}}},
+		{`a "" b`, []searchTerm{{tokens: []string{"a"}}, {tokens: []string{"b"}}}},
+	}
+	for _, tt := range tests {
+		got, err := parseSearch(tt.query)
+		if err != nil {
+			t.Errorf("parseSearch(%q): %v", tt.query, err)
+			continue
+		}
+		if !slices.EqualFunc(got, tt.want, func(a, b searchTerm) bool {
+			return slices.Equal(a.tokens, b.tokens) && a.prefix == b.prefix
+		}) {
+			t.Errorf("parseSearch(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, q := range []string{`"cache warmup`, "***", `"--"`} {
+		if _, err := parseSearch(q); !errors.Is(err, ErrValidation) {
+			t.Errorf("parseSearch(%q): %v, want ErrValidation", q, err)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	fixtures := []model.TouchpointInput{
+		{Description: "Cache warmup tuning for the release", Category: "Bug Fix"},
+		{Description: "Cache cache warmup", Category: "Bug Fix"},
+		{Description: "Warmed up the staging cluster", Category: "Bug Fix", PeopleInvolved: []string{"Grace"}},
+		{Description: "Reviewed the design with Grace", Category: "Code Review"},
+		{Description: "Deployment notes", Category: "Documentation", URL: "https://wiki.example.com/cache"},
+	}
+	const (
+		warmupTuning = "Cache warmup tuning for the release"
+		cacheCache   = "Cache cache warmup"
+		warmed       = "Warmed up the staging cluster"
+		graceDesign  = "Reviewed the design with Grace"
+		notes        = "Deployment notes"
+	)
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		// Repeated terms and shorter texts rank higher; a URL match counts
+		// for least.
+		{"cache", []string{cacheCache, warmupTuning, notes}},
+		{"CACHE", []string{cacheCache, warmupTuning, notes}},
+		// A person's name counts for more than the same word in a
+		// description.
+		{"grace", []string{warmed, graceDesign}},
+		{"warm*", []string{cacheCache, warmupTuning, warmed}},
+		{"warm", []string{}},
+		{"cache warmup", []string{cacheCache, warmupTuning}},
+		{`"cache warmup"`, []string{cacheCache, warmupTuning}},
+		{`"warmup cache"`, []string{}},
+		// Inside quotes a star is not a prefix.
+		{`"cache warm*"`, []string{}},
+		{`"staging cluster" grace`, []string{warmed}},
+		{"cache nothing", []string{}},
+	}
+
+	forEachBackend(t, func(t *testing.T, s backend) {
+		for _, input := range fixtures {
+			if _, err := s.CreateTouchpoint(input, "ada"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, tt := range tests {
+			page, err := s.ListTouchpoints(model.TouchpointFilter{Search: tt.search})
+			if err != nil {
+				t.Fatalf("search %q: %v", tt.search, err)
+			}
+			if got := descriptions(page.Touchpoints); !slices.Equal(got, tt.want) {
+				t.Errorf("search %q listed %q, want %q", tt.search, got, tt.want)
+			}
+		}
+	})
+}
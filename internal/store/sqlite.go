@@ -0,0 +1,275 @@
+package store
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/tanq16/ohara/internal/model"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order; PRAGMA user_version records how
+// many have run against a database file.
+var sqliteMigrations = []string{
+	`CREATE TABLE touchpoints (
+		seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+		id              TEXT NOT NULL UNIQUE,
+		date            TEXT NOT NULL,
+		description     TEXT NOT NULL,
+		category        TEXT NOT NULL,
+		tags            TEXT NOT NULL DEFAULT '[]',
+		people_involved TEXT NOT NULL DEFAULT '[]',
+		url             TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX idx_touchpoints_date ON touchpoints(date);
+	CREATE INDEX idx_touchpoints_category ON touchpoints(category);
+	CREATE TABLE categories (
+		seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE
+	);
+	CREATE TABLE tags (
+		seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE
+	);
+	CREATE TABLE reports (
+		filename   TEXT PRIMARY KEY,
+		content    TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);`,
+	`CREATE TABLE revisions (
+		touchpoint_id TEXT NOT NULL,
+		rev           INTEGER NOT NULL,
+		timestamp     TEXT NOT NULL,
+		author        TEXT NOT NULL,
+		action        TEXT NOT NULL,
+		changes       TEXT NOT NULL,
+		snapshot      TEXT NOT NULL,
+		PRIMARY KEY (touchpoint_id, rev)
+	);`,
+	`ALTER TABLE touchpoints ADD COLUMN deleted_at TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_touchpoints_deleted_at ON touchpoints(deleted_at);`,
+	// Full-text index over live touchpoints, keyed by touchpoints.seq. Column
+	// order matches the bm25 weights in ListTouchpoints.
+	`CREATE VIRTUAL TABLE touchpoints_fts USING fts5(
+		description, people, url,
+		tokenize = 'unicode61 remove_diacritics 0'
+	);
+	CREATE TRIGGER touchpoints_fts_insert AFTER INSERT ON touchpoints WHEN new.deleted_at = '' BEGIN
+		INSERT INTO touchpoints_fts (rowid, description, people, url)
+		VALUES (new.seq, new.description, (SELECT group_concat(value, ' ') FROM json_each(new.people_involved)), new.url);
+	END;
+	CREATE TRIGGER touchpoints_fts_delete AFTER DELETE ON touchpoints BEGIN
+		DELETE FROM touchpoints_fts WHERE rowid = old.seq;
+	END;
+	CREATE TRIGGER touchpoints_fts_update AFTER UPDATE ON touchpoints BEGIN
+		DELETE FROM touchpoints_fts WHERE rowid = old.seq;
+		INSERT INTO touchpoints_fts (rowid, description, people, url)
+		SELECT new.seq, new.description, (SELECT group_concat(value, ' ') FROM json_each(new.people_involved)), new.url
+		WHERE new.deleted_at = '';
+	END;
+	INSERT INTO touchpoints_fts (rowid, description, people, url)
+	SELECT seq, description, (SELECT group_concat(value, ' ') FROM json_each(people_involved)), url
+	FROM touchpoints WHERE deleted_at = '';`,
+	`ALTER TABLE reports ADD COLUMN modified_at TEXT NOT NULL DEFAULT '';
+	UPDATE reports SET modified_at = created_at;`,
+}
+
+type SQLiteStore struct {
+	dataDir   string
+	tolerance time.Duration
+	db        *sql.DB
+	sanitizer *bluemonday.Policy
+	onChange  changeHook
+}
+
+func NewSQLite(cfg Config) (*SQLiteStore, error) {
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ensureTemplates(cfg.DataDir); err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + filepath.Join(cfg.DataDir, "ohara.db") +
+		"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serializes writers, mirroring the JSON store's mutexes.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{
+		dataDir:   cfg.DataDir,
+		tolerance: cfg.FutureTolerance,
+		db:        db,
+		sanitizer: bluemonday.StrictPolicy(),
+		onChange:  cfg.OnChange,
+	}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate sqlite database: %w", err)
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Check reports whether the store can serve requests: the data directory
+// must be writable, for the database and its journal, and the database
+// must answer queries.
+func (s *SQLiteStore) Check() error {
+	if err := checkWritable(s.dataDir); err != nil {
+		return err
+	}
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	if version != len(sqliteMigrations) {
+		return fmt.Errorf("database schema is at version %d, expected %d", version, len(sqliteMigrations))
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Counts() (model.Counts, error) {
+	var c model.Counts
+	err := s.db.QueryRow(`SELECT
+		(SELECT count(*) FROM touchpoints WHERE deleted_at = ''),
+		(SELECT count(*) FROM touchpoints WHERE deleted_at != ''),
+		(SELECT count(*) FROM reports)`).Scan(&c.Touchpoints, &c.Trashed, &c.Reports)
+	return c, err
+}
+
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version >= len(sqliteMigrations) {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	if version == 0 {
+		if err := s.seed(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(sqliteMigrations))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// seed populates a freshly created database, carrying over any data left
+// by the JSON backend in the same data directory so switching is lossless.
+func (s *SQLiteStore) seed(tx *sql.Tx) error {
+	md := defaultMetadata()
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "metadata.json")); err == nil {
+		if err := json.Unmarshal(data, &md); err != nil {
+			return fmt.Errorf("failed to parse metadata.json: %w", err)
+		}
+	}
+	for _, c := range md.Categories {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO categories (name) VALUES (?)", c); err != nil {
+			return err
+		}
+	}
+	for _, t := range md.Tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", t); err != nil {
+			return err
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "touchpoints.json")); err == nil {
+		var tps []model.Touchpoint
+		if err := json.Unmarshal(data, &tps); err != nil {
+			return fmt.Errorf("failed to parse touchpoints.json: %w", err)
+		}
+		for _, tp := range tps {
+			if t, err := time.Parse(time.RFC3339, tp.Date); err == nil {
+				tp.Date = t.UTC().Format(time.RFC3339)
+			}
+			if err := insertTouchpoint(tx, tp); err != nil {
+				return err
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "history.json")); err == nil {
+		history := map[string][]model.Revision{}
+		if err := json.Unmarshal(data, &history); err != nil {
+			return fmt.Errorf("failed to parse history.json: %w", err)
+		}
+		for _, revs := range history {
+			for _, r := range revs {
+				if err := insertRevision(tx, r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.dataDir, "reports"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	created := map[string]string{}
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "reports.json")); err == nil {
+		if err := json.Unmarshal(data, &created); err != nil {
+			return fmt.Errorf("failed to parse reports.json: %w", err)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, e := range entries {
+		if e.IsDir() || validateReportFilename(e.Name()) != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(filepath.Join(s.dataDir, "reports", e.Name()))
+		if err != nil {
+			return err
+		}
+		modified := info.ModTime().UTC().Format(time.RFC3339)
+		if _, err := tx.Exec(
+			"INSERT INTO reports (filename, content, created_at, modified_at) VALUES (?, ?, ?, ?)",
+			e.Name(), string(content), cmp.Or(created[e.Name()], modified), modified,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func insertRevision(q queryer, r model.Revision) error {
+	changes, err := json.Marshal(r.Changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(r.Snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(
+		"INSERT INTO revisions (touchpoint_id, rev, timestamp, author, action, changes, snapshot) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		r.TouchpointID, r.Rev, r.Timestamp, r.Author, r.Action, string(changes), string(snapshot),
+	)
+	return err
+}
+
+func loadRevisions(q queryer, id string) ([]model.Revision, error) {
+	rows, err := q.Query(
+		"SELECT rev, timestamp, author, action, changes, snapshot FROM revisions WHERE touchpoint_id = ? ORDER BY rev", id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revs := make([]model.Revision, 0)
+	for rows.Next() {
+		r := model.Revision{TouchpointID: id}
+		var changes, snapshot string
+		if err := rows.Scan(&r.Rev, &r.Timestamp, &r.Author, &r.Action, &changes, &snapshot); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &r.Changes); err != nil {
+			return nil, fmt.Errorf("revision %d of touchpoint %s: invalid changes column: %w", r.Rev, id, err)
+		}
+		if err := json.Unmarshal([]byte(snapshot), &r.Snapshot); err != nil {
+			return nil, fmt.Errorf("revision %d of touchpoint %s: invalid snapshot column: %w", r.Rev, id, err)
+		}
+		revs = append(revs, r)
+	}
+	return revs, rows.Err()
+}
+
+// recordRevision appends a revision for a change made within tx.
+func recordRevision(tx *sql.Tx, action, author string, before, after model.Touchpoint) error {
+	id := after.ID
+	if action == actionDelete {
+		id = before.ID
+	}
+
+	var last int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(rev), 0) FROM revisions WHERE touchpoint_id = ?", id).Scan(&last); err != nil {
+		return err
+	}
+	if last == 0 && action != actionCreate {
+		if err := insertRevision(tx, baselineRevision(before)); err != nil {
+			return err
+		}
+		last = 1
+	}
+	return insertRevision(tx, newRevision(last+1, action, author, before, after))
+}
+
+func (s *SQLiteStore) TouchpointHistory(id string) ([]model.Revision, error) {
+	revs, err := loadRevisions(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) > 0 {
+		return revs, nil
+	}
+	tp, err := getTouchpoint(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return []model.Revision{baselineRevision(tp)}, nil
+}
+
+func (s *SQLiteStore) RestoreRevision(id string, rev int, author string) (model.Touchpoint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+	defer tx.Rollback()
+
+	revs, err := loadRevisions(tx, id)
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+	before, err := getTouchpoint(tx, id)
+	live := err == nil
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return model.Touchpoint{}, err
+	}
+	if live && before.DeletedAt != "" {
+		return model.Touchpoint{}, validationErr("touchpoint " + id + " is in the trash; restore it from there first")
+	}
+	if len(revs) == 0 && live {
+		revs = []model.Revision{baselineRevision(before)}
+	}
+	target, err := findRevision(revs, id, rev)
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	restored := target.Snapshot
+	if err := s.validateInput(tx, inputFromTouchpoint(restored)); err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	if live {
+		err = updateTouchpointRow(tx, restored)
+	} else {
+		err = insertTouchpoint(tx, restored)
+	}
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := recordRevision(tx, actionRestore, author, before, restored); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Touchpoint{}, err
+	}
+	s.onChange.notify(model.EventTouchpointUpdated, restored)
+	return restored, nil
+}
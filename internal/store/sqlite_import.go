@@ -0,0 +1,51 @@
+package store
+
+import (
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func (s *SQLiteStore) ImportTouchpoints(rows []model.ImportRow, opts model.ImportOptions, author string) (model.ImportReport, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.ImportReport{}, err
+	}
+	defer tx.Rollback()
+
+	md, err := loadSQLiteMetadata(tx)
+	if err != nil {
+		return model.ImportReport{}, err
+	}
+	imported, report := prepareImport(s.sanitizer, &md, rows, opts, s.tolerance)
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, c := range report.CreatedCategories {
+		if _, err := tx.Exec("INSERT INTO categories (name) VALUES (?)", c); err != nil {
+			return model.ImportReport{}, err
+		}
+	}
+	for _, t := range report.CreatedTags {
+		if _, err := tx.Exec("INSERT INTO tags (name) VALUES (?)", t); err != nil {
+			return model.ImportReport{}, err
+		}
+	}
+	for _, tp := range imported {
+		if err := insertTouchpoint(tx, tp); err != nil {
+			return model.ImportReport{}, err
+		}
+		if err := insertRevision(tx, newRevision(1, actionCreate, author, model.Touchpoint{}, tp)); err != nil {
+			return model.ImportReport{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return model.ImportReport{}, err
+	}
+	if len(report.CreatedCategories) > 0 || len(report.CreatedTags) > 0 {
+		s.onChange.notify(model.EventMetadataUpdated, md)
+	}
+	if len(imported) > 0 {
+		s.onChange.notify(model.EventTouchpointsImported, report)
+	}
+	return report, nil
+}
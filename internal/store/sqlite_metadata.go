@@ -0,0 +1,100 @@
+package store
+
+import (
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func loadSQLiteNames(q queryer, table string) ([]string, error) {
+	rows, err := q.Query("SELECT name FROM " + table + " ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func loadSQLiteMetadata(q queryer) (model.Metadata, error) {
+	categories, err := loadSQLiteNames(q, "categories")
+	if err != nil {
+		return model.Metadata{}, err
+	}
+	tags, err := loadSQLiteNames(q, "tags")
+	if err != nil {
+		return model.Metadata{}, err
+	}
+	return model.Metadata{Categories: categories, Tags: tags}, nil
+}
+
+func (s *SQLiteStore) addName(table, kind, name string) error {
+	if name == "" {
+		return validationErr(kind + " name is required")
+	}
+	return s.changeNames("INSERT OR IGNORE INTO "+table+" (name) VALUES (?)", name, alreadyExistsErr(kind, name))
+}
+
+func (s *SQLiteStore) removeName(table, kind, name string) error {
+	if name == "" {
+		return validationErr(kind + " name is required")
+	}
+	return s.changeNames("DELETE FROM "+table+" WHERE name = ?", name, notFoundErr(kind, name))
+}
+
+// changeNames runs a statement adding or removing name, returning noop if
+// it changed nothing, and announces the metadata as it then stands.
+func (s *SQLiteStore) changeNames(stmt, name string, noop error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(stmt, name)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return noop
+	}
+	md, err := loadSQLiteMetadata(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventMetadataUpdated, md)
+	return nil
+}
+
+func (s *SQLiteStore) GetMetadata() (model.Metadata, error) {
+	return loadSQLiteMetadata(s.db)
+}
+
+func (s *SQLiteStore) AddCategory(name string) error {
+	return s.addName("categories", "category", name)
+}
+
+func (s *SQLiteStore) RemoveCategory(name string) error {
+	return s.removeName("categories", "category", name)
+}
+
+func (s *SQLiteStore) AddTag(name string) error {
+	return s.addName("tags", "tag", name)
+}
+
+func (s *SQLiteStore) RemoveTag(name string) error {
+	return s.removeName("tags", "tag", name)
+}
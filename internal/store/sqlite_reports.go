@@ -0,0 +1,165 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func (s *SQLiteStore) ListReports(f model.ReportFilter) ([]model.ReportInfo, error) {
+	c, err := parseReportFilter(f)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT filename, content, created_at, modified_at FROM reports ORDER BY filename DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]model.ReportInfo, 0)
+	for rows.Next() {
+		var filename, content, createdAt, modifiedAt string
+		if err := rows.Scan(&filename, &content, &createdAt, &modifiedAt); err != nil {
+			return nil, err
+		}
+		created, _ := time.Parse(time.RFC3339, createdAt)
+		modified, _ := time.Parse(time.RFC3339, modifiedAt)
+		if r := reportInfo(filename, content, created, modified); c.match(r) {
+			reports = append(reports, r)
+		}
+	}
+	return reports, rows.Err()
+}
+
+func getSQLiteReport(q queryer, filename string) (string, error) {
+	if err := validateReportFilename(filename); err != nil {
+		return "", err
+	}
+
+	var content string
+	err := q.QueryRow("SELECT content FROM reports WHERE filename = ?", filename).Scan(&content)
+	if err == sql.ErrNoRows {
+		return "", notFoundErr("report", filename)
+	}
+	if err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+func (s *SQLiteStore) GetReport(filename string) (string, error) {
+	return getSQLiteReport(s.db, filename)
+}
+
+func (s *SQLiteStore) CreateReport(filename, content string) error {
+	if err := validateReportFilename(filename); err != nil {
+		return err
+	}
+
+	if err := validateReportContent(content); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	res, err := s.db.Exec(
+		"INSERT OR IGNORE INTO reports (filename, content, created_at, modified_at) VALUES (?, ?, ?, ?)",
+		filename, content, now, now,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return alreadyExistsErr("report", filename)
+	}
+	s.onChange.notify(model.EventReportPublished, reportChange(filename, content))
+	return nil
+}
+
+func (s *SQLiteStore) UpdateReport(filename, content, version string) error {
+	if err := validateReportContent(content); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := getSQLiteReport(tx, filename)
+	if err != nil {
+		return err
+	}
+	if err := checkReportVersion(filename, current, version); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE reports SET content = ?, modified_at = ? WHERE filename = ?",
+		content, time.Now().UTC().Format(time.RFC3339), filename,
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventReportUpdated, reportChange(filename, content))
+	return nil
+}
+
+func (s *SQLiteStore) RenameReport(filename, newFilename string) error {
+	if err := validateReportFilename(newFilename); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := getSQLiteReport(tx, filename); err != nil {
+		return err
+	}
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM reports WHERE filename = ?)", newFilename).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return alreadyExistsErr("report", newFilename)
+	}
+	if _, err := tx.Exec("UPDATE reports SET filename = ? WHERE filename = ?", newFilename, filename); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventReportRenamed, map[string]string{"filename": filename, "new_filename": newFilename})
+	return nil
+}
+
+func (s *SQLiteStore) DeleteReport(filename string) error {
+	if err := validateReportFilename(filename); err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec("DELETE FROM reports WHERE filename = ?", filename)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFoundErr("report", filename)
+	}
+	s.onChange.notify(model.EventReportDeleted, map[string]string{"filename": filename})
+	return nil
+}
@@ -0,0 +1,9 @@
+package store
+
+func (s *SQLiteStore) ListTemplates() ([]string, error) {
+	return listTemplates(s.dataDir)
+}
+
+func (s *SQLiteStore) GetTemplate(name string) (string, error) {
+	return getTemplate(s.dataDir, name)
+}
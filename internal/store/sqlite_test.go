@@ -0,0 +1,393 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// backend is the part of a store the tests drive; both *Store and
+// *SQLiteStore satisfy it.
+type backend interface {
+	ListTouchpoints(f model.TouchpointFilter) (model.TouchpointPage, error)
+	CreateTouchpoint(input model.TouchpointInput, author string) (model.Touchpoint, error)
+	UpdateTouchpoint(id string, input model.TouchpointInput, author string) (model.Touchpoint, error)
+	DeleteTouchpoint(id, author string) error
+	TouchpointHistory(id string) ([]model.Revision, error)
+	RestoreRevision(id string, rev int, author string) (model.Touchpoint, error)
+	ListTrash() ([]model.Touchpoint, error)
+	RestoreTouchpoint(id, author string) (model.Touchpoint, error)
+	PurgeTouchpoint(id string) error
+	PurgeTrash(before time.Time) (int, error)
+	GetMetadata() (model.Metadata, error)
+	ListReports(f model.ReportFilter) ([]model.ReportInfo, error)
+	CreateReport(filename, content string) error
+	Counts() (model.Counts, error)
+}
+
+// forEachBackend runs test against a fresh store of each backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, s backend)) {
+	t.Run("json", func(t *testing.T) {
+		s, err := New(Config{DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, s)
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := NewSQLite(Config{DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(t, s)
+	})
+}
+
+// listFixtures are created in order; tests name them by description.
+var listFixtures = []model.TouchpointInput{
+	{Date: "2025-01-15", Description: "Fixed login timeout bug", Category: "Bug Fix", Tags: []string{"backend", "security"}, PeopleInvolved: []string{"Alice"}, URL: "https://git.example.com/pr/1"},
+	{Date: "2025-02-01", Description: "Reviewed search ranking change", Category: "Code Review", Tags: []string{"backend"}, PeopleInvolved: []string{"Bob", "Carol"}},
+	{Date: "2024-11-20", Description: "Designed login page", Category: "Technical Design", Tags: []string{"frontend"}, PeopleInvolved: []string{"Alice"}},
+	{Date: "2025-03-03", Description: "Wrote deployment docs", Category: "Documentation", Tags: []string{"devops"}, URL: "https://wiki.example.com/deploy"},
+	{Date: "2025-02-01", Description: "Paired with Alice on flaky tests", Category: "Bug Fix", Tags: []string{"testing"}, PeopleInvolved: []string{"Dave"}},
+}
+
+const (
+	fixLogin   = "Fixed login timeout bug"
+	reviewed   = "Reviewed search ranking change"
+	designed   = "Designed login page"
+	wroteDocs  = "Wrote deployment docs"
+	pairedWith = "Paired with Alice on flaky tests"
+)
+
+func createFixtures(t *testing.T, s backend) []model.Touchpoint {
+	t.Helper()
+	var tps []model.Touchpoint
+	for _, input := range listFixtures {
+		tp, err := s.CreateTouchpoint(input, "ada")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tps = append(tps, tp)
+	}
+	return tps
+}
+
+func descriptions(tps []model.Touchpoint) []string {
+	out := []string{}
+	for _, tp := range tps {
+		out = append(out, tp.Description)
+	}
+	return out
+}
+
+// listAll follows cursors from the first page with the given page size.
+func listAll(t *testing.T, s backend, f model.TouchpointFilter, limit int) []model.Touchpoint {
+	t.Helper()
+	f.Limit = limit
+	var all []model.Touchpoint
+	for range 100 {
+		page, err := s.ListTouchpoints(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Touchpoints) > limit {
+			t.Fatalf("page of %d with limit %d", len(page.Touchpoints), limit)
+		}
+		all = append(all, page.Touchpoints...)
+		if page.NextCursor == "" {
+			return all
+		}
+		f.Cursor = page.NextCursor
+	}
+	t.Fatal("cursors never ran out")
+	return nil
+}
+
+// TestBackendsAgree runs the same listings against both backends, whole
+// and a page at a time.
+func TestBackendsAgree(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter model.TouchpointFilter
+		want   []string
+	}{
+		{"everything in creation order", model.TouchpointFilter{}, []string{fixLogin, reviewed, designed, wroteDocs, pairedWith}},
+		{"category", model.TouchpointFilter{Categories: []string{"Bug Fix"}}, []string{fixLogin, pairedWith}},
+		{"any category", model.TouchpointFilter{Categories: []string{"Bug Fix", "Documentation"}}, []string{fixLogin, wroteDocs, pairedWith}},
+		{"any tag", model.TouchpointFilter{Tags: []string{"frontend", "devops"}}, []string{designed, wroteDocs}},
+		{"category and tag", model.TouchpointFilter{Categories: []string{"Bug Fix"}, Tags: []string{"security"}}, []string{fixLogin}},
+		{"start date", model.TouchpointFilter{StartDate: "2025-02-01"}, []string{reviewed, wroteDocs, pairedWith}},
+		{"end date includes its day", model.TouchpointFilter{EndDate: "2025-02-01"}, []string{fixLogin, reviewed, designed, pairedWith}},
+		{"month", model.TouchpointFilter{StartDate: "2025-01", EndDate: "2025-01"}, []string{fixLogin}},
+		{"RFC3339 bounds", model.TouchpointFilter{StartDate: "2025-01-15T12:00:00Z", EndDate: "2025-02-01T11:59:59Z"}, []string{fixLogin}},
+		{"all search words", model.TouchpointFilter{Search: "login bug"}, []string{fixLogin}},
+		{"people outrank descriptions", model.TouchpointFilter{Search: "alice"}, []string{designed, fixLogin, pairedWith}},
+		{"prefix", model.TouchpointFilter{Search: "deploy*"}, []string{wroteDocs}},
+		{"phrase", model.TouchpointFilter{Search: `"login page"`}, []string{designed}},
+		{"phrase out of order", model.TouchpointFilter{Search: `"page login"`}, []string{}},
+		{"search and category", model.TouchpointFilter{Search: "login", Categories: []string{"Bug Fix"}}, []string{fixLogin}},
+		{"query", model.TouchpointFilter{Query: "tag:backend AND NOT person:bob"}, []string{fixLogin}},
+		{"date ascending", model.TouchpointFilter{Sort: "date"}, []string{designed, fixLogin, reviewed, pairedWith, wroteDocs}},
+		{"date descending", model.TouchpointFilter{Sort: "date", Order: "desc"}, []string{wroteDocs, reviewed, pairedWith, fixLogin, designed}},
+		{"category ascending", model.TouchpointFilter{Sort: "category"}, []string{fixLogin, pairedWith, reviewed, wroteDocs, designed}},
+		{"category descending", model.TouchpointFilter{Sort: "category", Order: "desc"}, []string{designed, wroteDocs, reviewed, pairedWith, fixLogin}},
+		{"search sorted by date", model.TouchpointFilter{Search: "alice", Sort: "date"}, []string{designed, fixLogin, pairedWith}},
+	}
+
+	forEachBackend(t, func(t *testing.T, s backend) {
+		createFixtures(t, s)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				page, err := s.ListTouchpoints(tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := descriptions(page.Touchpoints); !slices.Equal(got, tt.want) {
+					t.Errorf("listed %q, want %q", got, tt.want)
+				}
+				if page.NextCursor != "" {
+					t.Errorf("unlimited listing has a next cursor")
+				}
+				for _, limit := range []int{1, 2} {
+					if got := descriptions(listAll(t, s, tt.filter, limit)); !slices.Equal(got, tt.want) {
+						t.Errorf("pages of %d listed %q, want %q", limit, got, tt.want)
+					}
+				}
+			})
+		}
+	})
+}
+
+// openAtVersion creates a database with only the first version migrations
+// applied, as an older release would have left it.
+func openAtVersion(t *testing.T, dir string, version int) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "ohara.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range sqliteMigrations[:version] {
+		if _, err := db.Exec(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteMigratesOlderDatabase(t *testing.T) {
+	dir := t.TempDir()
+	// Version 3 predates full-text search and report modification times.
+	db := openAtVersion(t, dir, 3)
+	if _, err := db.Exec(`INSERT INTO touchpoints (id, date, description, category, people_involved)
+		VALUES ('old', '2025-01-01T12:00:00Z', 'Migrated onboarding guide', 'Documentation', '["Erin"]')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO reports (filename, content, created_at)
+		VALUES ('old.md', '# Old', '2025-01-02T00:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	// Seeding only fills new databases, so this must not be read.
+	if err := os.WriteFile(filepath.Join(dir, "touchpoints.json"), []byte(`[{"id":"json","date":"2025-01-01T00:00:00Z","description":"From JSON","category":"Bug Fix"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSQLite(Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := s.ListTouchpoints(model.TouchpointFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := descriptions(page.Touchpoints); !slices.Equal(got, []string{"Migrated onboarding guide"}) {
+		t.Errorf("listed %q after migrating", got)
+	}
+	for _, q := range []string{"onboarding", "erin"} {
+		page, err := s.ListTouchpoints(model.TouchpointFilter{Search: q})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Touchpoints) != 1 {
+			t.Errorf("search %q found %d existing touchpoints, want 1", q, len(page.Touchpoints))
+		}
+	}
+	reports, err := s.ListReports(model.ReportFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].ModifiedAt != "2025-01-02T00:00:00Z" {
+		t.Errorf("reports after migrating: %+v", reports)
+	}
+
+	// Reopening a current database runs nothing again.
+	s.Close()
+	s, err = NewSQLite(Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if c, err := s.Counts(); err != nil || c.Touchpoints != 1 {
+		t.Errorf("counts after reopening: %+v, %v", c, err)
+	}
+}
+
+func TestSQLiteSeedsFromJSON(t *testing.T) {
+	dir := t.TempDir()
+	js, err := New(Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := js.AddCategory("Research"); err != nil {
+		t.Fatal(err)
+	}
+	tps := createFixtures(t, js)
+	if _, err := js.UpdateTouchpoint(tps[0].ID, model.TouchpointInput{Description: "Fixed login timeout for good", Category: "Research", PeopleInvolved: []string{"Alice"}}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.DeleteTouchpoint(tps[1].ID, "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.CreateReport("q1.md", "---\ntitle: Q1\n---\n# Q1"); err != nil {
+		t.Fatal(err)
+	}
+	want, err := js.ListTouchpoints(model.TouchpointFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSQLite(Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	got, err := s.ListTouchpoints(model.TouchpointFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(got.Touchpoints, want.Touchpoints, func(a, b model.Touchpoint) bool {
+		return a.ID == b.ID && a.Date == b.Date && a.Description == b.Description && a.Category == b.Category &&
+			slices.Equal(a.Tags, b.Tags) && slices.Equal(a.PeopleInvolved, b.PeopleInvolved) && a.URL == b.URL
+	}) {
+		t.Errorf("seeded touchpoints %+v, want %+v", got.Touchpoints, want.Touchpoints)
+	}
+	trash, err := s.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].ID != tps[1].ID {
+		t.Errorf("seeded trash %+v", trash)
+	}
+	revs, err := s.TouchpointHistory(tps[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[1].Author != "bob" || revs[1].Action != actionUpdate {
+		t.Errorf("seeded history %+v", revs)
+	}
+	md, err := s.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(md.Categories, "Research") {
+		t.Errorf("seeded categories %v lack Research", md.Categories)
+	}
+	reports, err := s.ListReports(model.ReportFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Title != "Q1" {
+		t.Errorf("seeded reports %+v", reports)
+	}
+	// The full-text index covers seeded touchpoints.
+	page, err := s.ListTouchpoints(model.TouchpointFilter{Search: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := descriptions(page.Touchpoints); !slices.Equal(got, []string{designed, "Fixed login timeout for good", pairedWith}) {
+		t.Errorf("search over seeded touchpoints listed %q", got)
+	}
+}
+
+// TestSQLiteSearchFollowsChanges checks the triggers keeping the full-text
+// index in step with the touchpoints table.
+func TestSQLiteSearchFollowsChanges(t *testing.T) {
+	s, err := NewSQLite(Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	found := func(q string) int {
+		t.Helper()
+		page, err := s.ListTouchpoints(model.TouchpointFilter{Search: q})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(page.Touchpoints)
+	}
+	indexed := func() int {
+		t.Helper()
+		var n int
+		if err := s.db.QueryRow("SELECT count(*) FROM touchpoints_fts").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tp, err := s.CreateTouchpoint(model.TouchpointInput{Description: "Tuned caching layer", Category: "Bug Fix", PeopleInvolved: []string{"Frank"}}, "ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found("caching") != 1 || found("frank") != 1 {
+		t.Error("new touchpoint is not searchable")
+	}
+
+	if _, err := s.UpdateTouchpoint(tp.ID, model.TouchpointInput{Description: "Tuned indexing layer", Category: "Bug Fix"}, "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if found("caching") != 0 || found("frank") != 0 || found("indexing") != 1 {
+		t.Error("update did not replace the indexed text")
+	}
+
+	if err := s.DeleteTouchpoint(tp.ID, "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if found("indexing") != 0 || indexed() != 0 {
+		t.Error("trashed touchpoint is still indexed")
+	}
+
+	if _, err := s.RestoreTouchpoint(tp.ID, "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if found("indexing") != 1 || indexed() != 1 {
+		t.Error("restored touchpoint is not indexed")
+	}
+
+	if err := s.DeleteTouchpoint(tp.ID, "ada"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PurgeTouchpoint(tp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if indexed() != 0 {
+		t.Error("purged touchpoint is still indexed")
+	}
+}
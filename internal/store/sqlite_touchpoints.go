@@ -0,0 +1,304 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tanq16/ohara/internal/model"
+)
+
+const touchpointColumns = "id, date, description, category, tags, people_involved, url, deleted_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTouchpoint(row rowScanner) (model.Touchpoint, error) {
+	var tp model.Touchpoint
+	var tags, people string
+	if err := row.Scan(&tp.ID, &tp.Date, &tp.Description, &tp.Category, &tags, &people, &tp.URL, &tp.DeletedAt); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &tp.Tags); err != nil {
+		return model.Touchpoint{}, fmt.Errorf("touchpoint %s: invalid tags column: %w", tp.ID, err)
+	}
+	if err := json.Unmarshal([]byte(people), &tp.PeopleInvolved); err != nil {
+		return model.Touchpoint{}, fmt.Errorf("touchpoint %s: invalid people_involved column: %w", tp.ID, err)
+	}
+	return tp, nil
+}
+
+func getTouchpoint(q queryer, id string) (model.Touchpoint, error) {
+	row := q.QueryRow("SELECT "+touchpointColumns+" FROM touchpoints WHERE id = ?", id)
+	tp, err := scanTouchpoint(row)
+	if err == sql.ErrNoRows {
+		return model.Touchpoint{}, notFoundErr("touchpoint", id)
+	}
+	return tp, err
+}
+
+func queryTouchpoints(q queryer, query string, args ...any) ([]model.Touchpoint, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]model.Touchpoint, 0)
+	for rows.Next() {
+		tp, err := scanTouchpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tp)
+	}
+	return result, rows.Err()
+}
+
+// getLiveTouchpoint is getTouchpoint, but treats trashed entries as missing.
+func getLiveTouchpoint(q queryer, id string) (model.Touchpoint, error) {
+	tp, err := getTouchpoint(q, id)
+	if err == nil && tp.DeletedAt != "" {
+		return model.Touchpoint{}, notFoundErr("touchpoint", id)
+	}
+	return tp, err
+}
+
+func insertTouchpoint(q queryer, tp model.Touchpoint) error {
+	tags, err := json.Marshal(tp.Tags)
+	if err != nil {
+		return err
+	}
+	people, err := json.Marshal(tp.PeopleInvolved)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(
+		"INSERT INTO touchpoints ("+touchpointColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		tp.ID, tp.Date, tp.Description, tp.Category, string(tags), string(people), tp.URL, tp.DeletedAt,
+	)
+	return err
+}
+
+func updateTouchpointRow(q queryer, tp model.Touchpoint) error {
+	tags, err := json.Marshal(tp.Tags)
+	if err != nil {
+		return err
+	}
+	people, err := json.Marshal(tp.PeopleInvolved)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(
+		"UPDATE touchpoints SET date = ?, description = ?, category = ?, tags = ?, people_involved = ?, url = ?, deleted_at = ? WHERE id = ?",
+		tp.Date, tp.Description, tp.Category, string(tags), string(people), tp.URL, tp.DeletedAt, tp.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) validateInput(q queryer, input model.TouchpointInput) error {
+	md, err := loadSQLiteMetadata(q)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata for validation: %w", err)
+	}
+	return validateAgainst(md, input)
+}
+
+// ftsExpression renders parsed search terms as an FTS5 MATCH expression.
+// Tokens only ever contain letters and digits, so quoting them is enough.
+func ftsExpression(terms []searchTerm) string {
+	clauses := make([]string, len(terms))
+	for i, t := range terms {
+		clauses[i] = `"` + strings.Join(t.tokens, " ") + `"`
+		if t.prefix {
+			clauses[i] += "*"
+		}
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+func (s *SQLiteStore) ListTouchpoints(f model.TouchpointFilter) (model.TouchpointPage, error) {
+	c, err := parseFilter(f)
+	if err != nil {
+		return model.TouchpointPage{}, err
+	}
+
+	var query string
+	var args []any
+	if len(c.search) > 0 {
+		query = "WITH hits AS (" +
+			"SELECT rowid, bm25(touchpoints_fts, 1.0, 2.0, 0.5) AS score FROM touchpoints_fts WHERE touchpoints_fts MATCH ?" +
+			") SELECT " + touchpointColumns + " FROM touchpoints JOIN hits ON hits.rowid = touchpoints.seq WHERE deleted_at = ''"
+		args = append(args, ftsExpression(c.search))
+	} else {
+		query = "SELECT " + touchpointColumns + " FROM touchpoints WHERE deleted_at = ''"
+	}
+
+	if len(c.categories) > 0 {
+		query += " AND category IN (" + placeholders(len(c.categories)) + ")"
+		for _, cat := range c.categories {
+			args = append(args, cat)
+		}
+	}
+	if len(c.tags) > 0 {
+		query += " AND EXISTS (SELECT 1 FROM json_each(touchpoints.tags) WHERE json_each.value IN (" + placeholders(len(c.tags)) + "))"
+		for _, tag := range c.tags {
+			args = append(args, tag)
+		}
+	}
+	// Dates are always written as UTC RFC3339, so they order lexically.
+	if !c.start.IsZero() {
+		query += " AND date >= ?"
+		args = append(args, c.start.UTC().Format(time.RFC3339))
+	}
+	if !c.end.IsZero() {
+		query += " AND date < ?"
+		args = append(args, c.end.UTC().Format(time.RFC3339))
+	}
+
+	direction := "ASC"
+	if c.desc {
+		direction = "DESC"
+	}
+	switch {
+	case c.sort == "date":
+		query += " ORDER BY date " + direction + ", seq"
+	case c.sort == "category":
+		query += " ORDER BY category " + direction + ", date " + direction + ", seq"
+	case len(c.search) > 0:
+		// bm25 scores are negative; the best match sorts first.
+		query += " ORDER BY hits.score, seq"
+	default:
+		query += " ORDER BY seq"
+	}
+
+	tps, err := queryTouchpoints(s.db, query, args...)
+	if err != nil {
+		return model.TouchpointPage{}, err
+	}
+	if c.query != nil {
+		// Query expressions are evaluated in Go so both backends agree exactly.
+		tps = slices.DeleteFunc(tps, func(tp model.Touchpoint) bool { return !c.query.match(tp) })
+	}
+	return paginate(tps, c)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (s *SQLiteStore) CreateTouchpoint(input model.TouchpointInput, author string) (model.Touchpoint, error) {
+	sanitizeInput(s.sanitizer, &input)
+	if err := normalizeDate(&input, s.tolerance); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if input.Date == "" {
+		input.Date = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+	defer tx.Rollback()
+
+	if err := s.validateInput(tx, input); err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	tp := model.Touchpoint{
+		ID:             uuid.New().String(),
+		Date:           input.Date,
+		Description:    input.Description,
+		Category:       input.Category,
+		Tags:           input.Tags,
+		PeopleInvolved: input.PeopleInvolved,
+		URL:            input.URL,
+	}
+	if err := insertTouchpoint(tx, tp); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := recordRevision(tx, actionCreate, author, model.Touchpoint{}, tp); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Touchpoint{}, err
+	}
+	s.onChange.notify(model.EventTouchpointCreated, tp)
+	return tp, nil
+}
+
+func (s *SQLiteStore) UpdateTouchpoint(id string, input model.TouchpointInput, author string) (model.Touchpoint, error) {
+	sanitizeInput(s.sanitizer, &input)
+	if err := normalizeDate(&input, s.tolerance); err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+	defer tx.Rollback()
+
+	if err := s.validateInput(tx, input); err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	before, err := getLiveTouchpoint(tx, id)
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	tp := before
+	if input.Date != "" {
+		tp.Date = input.Date
+	}
+	tp.Description = input.Description
+	tp.Category = input.Category
+	tp.Tags = input.Tags
+	tp.PeopleInvolved = input.PeopleInvolved
+	tp.URL = input.URL
+
+	if err := updateTouchpointRow(tx, tp); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := recordRevision(tx, actionUpdate, author, before, tp); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Touchpoint{}, err
+	}
+	s.onChange.notify(model.EventTouchpointUpdated, tp)
+	return tp, nil
+}
+
+func (s *SQLiteStore) DeleteTouchpoint(id, author string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getLiveTouchpoint(tx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE touchpoints SET deleted_at = ? WHERE id = ?", time.Now().UTC().Format(time.RFC3339), id,
+	); err != nil {
+		return err
+	}
+	if err := recordRevision(tx, actionDelete, author, before, model.Touchpoint{}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventTouchpointDeleted, map[string]string{"id": id})
+	return nil
+}
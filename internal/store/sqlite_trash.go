@@ -0,0 +1,105 @@
+package store
+
+import (
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func (s *SQLiteStore) ListTrash() ([]model.Touchpoint, error) {
+	result, err := queryTouchpoints(s.db, "SELECT "+touchpointColumns+" FROM touchpoints WHERE deleted_at != '' ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	sortTrash(result)
+	return result, nil
+}
+
+// getTrashedTouchpoint is getTouchpoint, but treats live entries as missing.
+func getTrashedTouchpoint(q queryer, id string) (model.Touchpoint, error) {
+	tp, err := getTouchpoint(q, id)
+	if err == nil && tp.DeletedAt == "" {
+		return model.Touchpoint{}, notFoundErr("trashed touchpoint", id)
+	}
+	return tp, err
+}
+
+func (s *SQLiteStore) RestoreTouchpoint(id, author string) (model.Touchpoint, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+	defer tx.Rollback()
+
+	before, err := getTrashedTouchpoint(tx, id)
+	if err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	tp := before
+	tp.DeletedAt = ""
+	if _, err := tx.Exec("UPDATE touchpoints SET deleted_at = '' WHERE id = ?", id); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := recordRevision(tx, actionRestore, author, before, tp); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Touchpoint{}, err
+	}
+	// To anyone watching, coming back from the trash is the same as being
+	// created again.
+	s.onChange.notify(model.EventTouchpointCreated, tp)
+	return tp, nil
+}
+
+func (s *SQLiteStore) PurgeTouchpoint(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := getTrashedTouchpoint(tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM touchpoints WHERE id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM revisions WHERE touchpoint_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) PurgeTrash(before time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	trashed, err := queryTouchpoints(tx, "SELECT "+touchpointColumns+" FROM touchpoints WHERE deleted_at != ''")
+	if err != nil {
+		return 0, err
+	}
+	var purged []string
+	for _, tp := range trashed {
+		if trashedBefore(tp, before) {
+			purged = append(purged, tp.ID)
+		}
+	}
+
+	for _, id := range purged {
+		if _, err := tx.Exec("DELETE FROM touchpoints WHERE id = ?", id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec("DELETE FROM revisions WHERE touchpoint_id = ?", id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
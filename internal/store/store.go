@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/tanq16/ohara/internal/model"
@@ -17,6 +18,8 @@ var (
 	ErrAlreadyExists   = errors.New("already exists")
 	ErrValidation      = errors.New("validation error")
 	ErrInvalidFilename = errors.New("invalid filename")
+	// ErrConflict means the caller's view of a resource is out of date.
+	ErrConflict = errors.New("conflict")
 )
 
 func notFoundErr(kind, id string) error {
@@ -27,6 +30,10 @@ func alreadyExistsErr(kind, name string) error {
 	return fmt.Errorf("%s %s: %w", kind, name, ErrAlreadyExists)
 }
 
+func conflictErr(kind, id string) error {
+	return fmt.Errorf("%s %s has changed: %w", kind, id, ErrConflict)
+}
+
 func validationErr(msg string) error {
 	return fmt.Errorf("%s: %w", msg, ErrValidation)
 }
@@ -42,24 +49,62 @@ func contains(slice []string, item string) bool {
 
 type Config struct {
 	DataDir string
+	// FutureTolerance is how far past the current time a touchpoint date
+	// may be, to absorb clock and timezone skew.
+	FutureTolerance time.Duration
+	// OnChange, if set, is told about every change once it is saved, with
+	// one of the model.Event* types and its payload. It runs before the
+	// changing method returns, possibly while the store holds locks, so it
+	// must be quick and must not call back into the store.
+	OnChange func(typ string, data any)
+}
+
+// changeHook passes changes on to Config.OnChange.
+type changeHook func(typ string, data any)
+
+func (h changeHook) notify(typ string, data any) {
+	if h != nil {
+		h(typ, data)
+	}
+}
+
+// reportChange is the payload of events about a report's content.
+func reportChange(filename, content string) model.ReportDocument {
+	fm, _ := ParseReport(filename, content)
+	return model.ReportDocument{Filename: filename, Content: content, ReportFrontMatter: fm}
 }
 
 type Store struct {
 	dataDir   string
+	tolerance time.Duration
 	tpMu      sync.RWMutex
 	mdMu      sync.RWMutex
+	rpMu      sync.Mutex
 	sanitizer *bluemonday.Policy
+	onChange  changeHook
+	// tps and history are guarded by tpMu and replaced wholesale on save.
+	// tpFile and historyFile are the versions of the files they hold, so
+	// saves by other processes on the same directory are noticed.
+	tps         *touchpointIndex
+	history     map[string][]model.Revision
+	tpFile      os.FileInfo
+	historyFile os.FileInfo
 }
 
 func New(cfg Config) (*Store, error) {
 	s := &Store{
 		dataDir:   cfg.DataDir,
+		tolerance: cfg.FutureTolerance,
 		sanitizer: bluemonday.StrictPolicy(),
+		onChange:  cfg.OnChange,
 	}
 
 	if err := os.MkdirAll(filepath.Join(cfg.DataDir, "reports"), 0755); err != nil {
 		return nil, err
 	}
+	if err := ensureTemplates(cfg.DataDir); err != nil {
+		return nil, err
+	}
 
 	tpPath := filepath.Join(cfg.DataDir, "touchpoints.json")
 	if _, err := os.Stat(tpPath); os.IsNotExist(err) {
@@ -70,22 +115,7 @@ func New(cfg Config) (*Store, error) {
 
 	mdPath := filepath.Join(cfg.DataDir, "metadata.json")
 	if _, err := os.Stat(mdPath); os.IsNotExist(err) {
-		defaults := model.Metadata{
-			Categories: []string{
-				"Feature Development",
-				"Bug Fix",
-				"Code Review",
-				"Technical Design",
-				"Tooling & Infrastructure",
-				"Documentation",
-				"Incident Response",
-				"Mentorship",
-				"Cross-team Collaboration",
-				"Knowledge Sharing",
-			},
-			Tags: []string{"backend", "frontend", "devops", "testing", "security", "performance"},
-		}
-		data, err := json.MarshalIndent(defaults, "", "  ")
+		data, err := json.MarshalIndent(defaultMetadata(), "", "  ")
 		if err != nil {
 			return nil, err
 		}
@@ -94,9 +124,101 @@ func New(cfg Config) (*Store, error) {
 		}
 	}
 
+	if err := s.load(); err != nil {
+		return nil, err
+	}
 	return s, nil
 }
 
+// load reads touchpoints and history into memory. Callers must hold tpMu
+// for writing, once the store is shared.
+func (s *Store) load() error {
+	// Stat first: a save landing in between is then read but not
+	// recorded, so it only costs a second load.
+	tpFile, historyFile := statFile(s.touchpointsPath()), statFile(s.historyPath())
+
+	tps, err := s.loadTouchpoints()
+	if err != nil {
+		return fmt.Errorf("failed to load touchpoints: %w", err)
+	}
+	history, err := s.loadHistory()
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+	s.tps, s.history = newTouchpointIndex(tps), history
+	s.tpFile, s.historyFile = tpFile, historyFile
+	return nil
+}
+
+// changedOnDisk reports whether another process, such as ohara import
+// next to a running server, has saved touchpoints or history since this
+// store last read or wrote them. Callers must hold tpMu.
+func (s *Store) changedOnDisk() bool {
+	return !sameVersion(s.tpFile, statFile(s.touchpointsPath())) ||
+		!sameVersion(s.historyFile, statFile(s.historyPath()))
+}
+
+// reloadChanged reloads touchpoints and history if another process has
+// saved them, so a save never overwrites what it wrote. Callers must hold
+// tpMu for writing.
+func (s *Store) reloadChanged() error {
+	if !s.changedOnDisk() {
+		return nil
+	}
+	return s.load()
+}
+
+// refresh is reloadChanged for readers, which take tpMu for writing only
+// when there is something to reload.
+func (s *Store) refresh() error {
+	s.tpMu.RLock()
+	changed := s.changedOnDisk()
+	s.tpMu.RUnlock()
+	if !changed {
+		return nil
+	}
+	s.tpMu.Lock()
+	defer s.tpMu.Unlock()
+	return s.reloadChanged()
+}
+
+// statFile returns nil for files that do not exist yet.
+func statFile(path string) os.FileInfo {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// sameVersion reports whether two stats are of the same save of a file.
+// atomicWrite renames a new file into place, so every save changes the
+// file's identity as well as, usually, its modification time.
+func sameVersion(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+func defaultMetadata() model.Metadata {
+	return model.Metadata{
+		Categories: []string{
+			"Feature Development",
+			"Bug Fix",
+			"Code Review",
+			"Technical Design",
+			"Tooling & Infrastructure",
+			"Documentation",
+			"Incident Response",
+			"Mentorship",
+			"Cross-team Collaboration",
+			"Knowledge Sharing",
+		},
+		Tags: []string{"backend", "frontend", "devops", "testing", "security", "performance"},
+	}
+}
+
 func (s *Store) touchpointsPath() string {
 	return filepath.Join(s.dataDir, "touchpoints.json")
 }
@@ -105,6 +227,14 @@ func (s *Store) metadataPath() string {
 	return filepath.Join(s.dataDir, "metadata.json")
 }
 
+func (s *Store) historyPath() string {
+	return filepath.Join(s.dataDir, "history.json")
+}
+
+func (s *Store) reportIndexPath() string {
+	return filepath.Join(s.dataDir, "reports.json")
+}
+
 func (s *Store) reportsDir() string {
 	return filepath.Join(s.dataDir, "reports")
 }
@@ -116,3 +246,107 @@ func atomicWrite(path string, data []byte) error {
 	}
 	return os.Rename(tmp, path)
 }
+
+// checkWritable makes sure files can be created in dir.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".ohara-check-*")
+	if err != nil {
+		return fmt.Errorf("data directory is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Check reports whether the store can serve requests: the data directory
+// must be writable and every JSON file in it must parse.
+func (s *Store) Check() error {
+	if err := checkWritable(s.dataDir); err != nil {
+		return err
+	}
+
+	s.tpMu.RLock()
+	_, err := s.loadTouchpoints()
+	if err != nil {
+		err = fmt.Errorf("touchpoints.json: %w", err)
+	} else if _, err = s.loadHistory(); err != nil {
+		err = fmt.Errorf("history.json: %w", err)
+	}
+	s.tpMu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	s.mdMu.RLock()
+	_, err = s.loadMetadata()
+	s.mdMu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("metadata.json: %w", err)
+	}
+
+	s.rpMu.Lock()
+	_, err = s.loadReportIndex()
+	s.rpMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("reports.json: %w", err)
+	}
+	return nil
+}
+
+// Counts sizes the in-memory index and the reports directory, without
+// parsing any report.
+func (s *Store) Counts() (model.Counts, error) {
+	if err := s.refresh(); err != nil {
+		return model.Counts{}, err
+	}
+	s.tpMu.RLock()
+	c := model.Counts{Touchpoints: len(s.tps.live), Trashed: len(s.tps.trash)}
+	s.tpMu.RUnlock()
+
+	s.rpMu.Lock()
+	defer s.rpMu.Unlock()
+	entries, err := os.ReadDir(s.reportsDir())
+	if err != nil {
+		return model.Counts{}, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && validateReportFilename(e.Name()) == nil {
+			c.Reports++
+		}
+	}
+	return c, nil
+}
+
+// dataFiles are the entries either backend keeps in a data directory.
+var dataFiles = []string{
+	"touchpoints.json", "metadata.json", "history.json", "reports.json",
+	"reports", "templates",
+	"ohara.db", "ohara.db-wal", "ohara.db-shm",
+}
+
+// MoveData moves a data directory's touchpoints, metadata, history, reports
+// and templates, for either backend, into another directory that holds none
+// yet. Neither directory may be open in a running server. It returns how
+// many entries were moved.
+func MoveData(from, to string) (int, error) {
+	for _, name := range dataFiles {
+		if _, err := os.Stat(filepath.Join(to, name)); err == nil {
+			return 0, fmt.Errorf("%s already has data (%s)", to, name)
+		}
+	}
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, name := range dataFiles {
+		err := os.Rename(filepath.Join(from, name), filepath.Join(to, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// TestReloadsOutsideChanges runs two stores on one directory, as a server
+// and ohara import would, and checks neither loses the other's saves.
+func TestReloadsOutsideChanges(t *testing.T) {
+	server, _ := newJSONStore(t)
+	cli, err := New(Config{DataDir: server.dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(s *Store, desc string) model.Touchpoint {
+		t.Helper()
+		tp, err := s.CreateTouchpoint(model.TouchpointInput{Description: desc, Category: "Bug Fix"}, "ada")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tp
+	}
+	count := func(s *Store) int {
+		t.Helper()
+		page, err := s.ListTouchpoints(model.TouchpointFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(page.Touchpoints)
+	}
+
+	first := create(server, "from the server")
+	if n := count(cli); n != 1 {
+		t.Fatalf("other store lists %d touchpoints, want 1", n)
+	}
+	imported := create(cli, "from the command line")
+	create(server, "from the server again")
+
+	for name, s := range map[string]*Store{"server": server, "cli": cli} {
+		if n := count(s); n != 3 {
+			t.Errorf("%s lists %d touchpoints, want 3", name, n)
+		}
+		for _, id := range []string{first.ID, imported.ID} {
+			if revs, err := s.TouchpointHistory(id); err != nil || len(revs) != 1 || revs[0].Author != "ada" {
+				t.Errorf("%s history of %s: %+v, %v", name, id, revs, err)
+			}
+		}
+	}
+
+	if err := cli.DeleteTouchpoint(first.ID, "ada"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := server.Counts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Touchpoints != 2 || c.Trashed != 1 {
+		t.Errorf("server counts %+v after a delete elsewhere", c)
+	}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Report templates are Go text/template files named <name>.tmpl under
+// <data-dir>/templates. They are plain files for both backends so they can
+// be edited in place.
+
+const templateExt = ".tmpl"
+
+// defaultTemplate is written as brag.tmpl when the templates directory is
+// first created.
+const defaultTemplate = `---
+title: {{quote .Title}}
+author: {{quote .Author}}
+status: draft
+{{- if or .StartDate .EndDate}}
+period:
+{{- with .StartDate}}
+  start: {{quote .}}
+{{- end}}
+{{- with .EndDate}}
+  end: {{quote .}}
+{{- end}}
+{{- end}}
+touchpoints:
+{{- range .Touchpoints}}
+  - {{.ID}}
+{{- end}}
+---
+
+# {{.Title}}
+
+{{with .StartDate}}From {{.}} {{end}}{{with .EndDate}}until {{.}} {{end}}({{.Count}} touchpoint{{if ne .Count 1}}s{{end}})
+{{range .Groups}}
+## {{.Name}} ({{len .Touchpoints}})
+
+{{range .Touchpoints -}}
+- **{{date "Jan 2, 2006" .Date}}**{{if ne $.GroupBy "category"}} · {{.Category}}{{end}} · {{.Description}}
+{{- with .PeopleInvolved}} (with {{join . ", "}}){{end}}
+{{- with .URL}} [link]({{.}}){{end}}
+{{end}}{{end}}`
+
+var validTemplateName = regexp.MustCompile(`^[\w][\w\-]*$`)
+
+func validateTemplateName(name string) error {
+	if !validTemplateName.MatchString(name) {
+		return fmt.Errorf("template name %s (must be alphanumeric/hyphens): %w", name, ErrInvalidFilename)
+	}
+	return nil
+}
+
+func templatesDir(dataDir string) string {
+	return filepath.Join(dataDir, "templates")
+}
+
+// ensureTemplates creates the templates directory with the default
+// template, leaving an existing directory alone.
+func ensureTemplates(dataDir string) error {
+	dir := templatesDir(dataDir)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "brag"+templateExt), []byte(defaultTemplate), 0644)
+}
+
+func listTemplates(dataDir string) ([]string, error) {
+	entries, err := os.ReadDir(templatesDir(dataDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), templateExt)
+		if !e.IsDir() && name != e.Name() && validateTemplateName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func getTemplate(dataDir, name string) (string, error) {
+	if err := validateTemplateName(name); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(templatesDir(dataDir), name+templateExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", notFoundErr("template", name)
+		}
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s *Store) ListTemplates() ([]string, error) {
+	return listTemplates(s.dataDir)
+}
+
+func (s *Store) GetTemplate(name string) (string, error) {
+	return getTemplate(s.dataDir, name)
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
 	"github.com/tanq16/ohara/internal/model"
 )
 
@@ -27,32 +29,70 @@ func (s *Store) saveTouchpoints(tps []model.Touchpoint) error {
 	if err != nil {
 		return err
 	}
-	return atomicWrite(s.touchpointsPath(), data)
+	if err := atomicWrite(s.touchpointsPath(), data); err != nil {
+		return err
+	}
+	s.tps = newTouchpointIndex(tps)
+	s.tpFile = statFile(s.touchpointsPath())
+	return nil
+}
+
+// touchpoints returns a copy of the cached touchpoints that callers may
+// modify and hand to saveTouchpoints.
+func (s *Store) touchpoints() []model.Touchpoint {
+	return slices.Clone(s.tps.all)
 }
 
-func (s *Store) sanitizeInput(input *model.TouchpointInput) {
-	input.Description = s.sanitizer.Sanitize(input.Description)
-	input.URL = s.sanitizer.Sanitize(input.URL)
+func sanitizeInput(p *bluemonday.Policy, input *model.TouchpointInput) {
+	input.Description = p.Sanitize(input.Description)
+	input.URL = p.Sanitize(input.URL)
 	if input.Tags == nil {
 		input.Tags = []string{}
 	}
 	if input.PeopleInvolved == nil {
 		input.PeopleInvolved = []string{}
 	}
-	for i, p := range input.PeopleInvolved {
-		input.PeopleInvolved[i] = s.sanitizer.Sanitize(p)
+	for i, person := range input.PeopleInvolved {
+		input.PeopleInvolved[i] = p.Sanitize(person)
 	}
 }
 
-func (s *Store) validateInput(input model.TouchpointInput) error {
-	if input.Description == "" {
-		return validationErr("description is required")
+// normalizeDate validates an optional input date and rewrites it as UTC
+// RFC3339. Date-only values are pinned to noon UTC so they fall on the same
+// calendar day in every timezone within twelve hours of UTC.
+func normalizeDate(input *model.TouchpointInput, tolerance time.Duration) error {
+	if input.Date == "" {
+		return nil
 	}
 
+	var t, earliest time.Time
+	if d, err := time.Parse(time.DateOnly, input.Date); err == nil {
+		t, earliest = d.Add(12*time.Hour), d
+	} else if d, err := time.Parse(time.RFC3339, input.Date); err == nil {
+		t, earliest = d, d
+	} else {
+		return validationErr(fmt.Sprintf("invalid date: %s (expected RFC3339 or YYYY-MM-DD)", input.Date))
+	}
+
+	if earliest.After(time.Now().Add(tolerance)) {
+		return validationErr(fmt.Sprintf("date %s is in the future", input.Date))
+	}
+	input.Date = t.UTC().Format(time.RFC3339)
+	return nil
+}
+
+func (s *Store) validateInput(input model.TouchpointInput) error {
 	md, err := s.loadMetadata()
 	if err != nil {
 		return fmt.Errorf("failed to load metadata for validation: %w", err)
 	}
+	return validateAgainst(md, input)
+}
+
+func validateAgainst(md model.Metadata, input model.TouchpointInput) error {
+	if input.Description == "" {
+		return validationErr("description is required")
+	}
 
 	if !contains(md.Categories, input.Category) {
 		return validationErr(fmt.Sprintf("unknown category: %s", input.Category))
@@ -67,51 +107,35 @@ func (s *Store) validateInput(input model.TouchpointInput) error {
 	return nil
 }
 
-func (s *Store) ListTouchpoints(category, tag, startDate string) ([]model.Touchpoint, error) {
-	s.tpMu.RLock()
-	defer s.tpMu.RUnlock()
-
-	tps, err := s.loadTouchpoints()
+func (s *Store) ListTouchpoints(f model.TouchpointFilter) (model.TouchpointPage, error) {
+	c, err := parseFilter(f)
 	if err != nil {
-		return nil, err
-	}
-
-	var start time.Time
-	if startDate != "" {
-		start, err = time.Parse(time.RFC3339, startDate)
-		if err != nil {
-			return nil, validationErr(fmt.Sprintf("invalid start_date format: %s", startDate))
-		}
+		return model.TouchpointPage{}, err
 	}
 
-	result := make([]model.Touchpoint, 0, len(tps))
-	for _, tp := range tps {
-		if category != "" && tp.Category != category {
-			continue
-		}
-		if tag != "" && !contains(tp.Tags, tag) {
-			continue
-		}
-		if !start.IsZero() {
-			t, err := time.Parse(time.RFC3339, tp.Date)
-			if err != nil {
-				continue
-			}
-			if t.Before(start) {
-				continue
-			}
-		}
-		result = append(result, tp)
+	if err := s.refresh(); err != nil {
+		return model.TouchpointPage{}, err
 	}
+	s.tpMu.RLock()
+	defer s.tpMu.RUnlock()
 
-	return result, nil
+	return paginate(s.tps.filter(c), c)
 }
 
-func (s *Store) CreateTouchpoint(input model.TouchpointInput) (model.Touchpoint, error) {
-	s.sanitizeInput(&input)
+func (s *Store) CreateTouchpoint(input model.TouchpointInput, author string) (model.Touchpoint, error) {
+	sanitizeInput(s.sanitizer, &input)
+	if err := normalizeDate(&input, s.tolerance); err != nil {
+		return model.Touchpoint{}, err
+	}
+	if input.Date == "" {
+		input.Date = time.Now().UTC().Format(time.RFC3339)
+	}
 
 	s.tpMu.Lock()
 	defer s.tpMu.Unlock()
+	if err := s.reloadChanged(); err != nil {
+		return model.Touchpoint{}, err
+	}
 
 	s.mdMu.RLock()
 	err := s.validateInput(input)
@@ -120,14 +144,11 @@ func (s *Store) CreateTouchpoint(input model.TouchpointInput) (model.Touchpoint,
 		return model.Touchpoint{}, err
 	}
 
-	tps, err := s.loadTouchpoints()
-	if err != nil {
-		return model.Touchpoint{}, err
-	}
+	tps := s.touchpoints()
 
 	tp := model.Touchpoint{
 		ID:             uuid.New().String(),
-		Date:           time.Now().UTC().Format(time.RFC3339),
+		Date:           input.Date,
 		Description:    input.Description,
 		Category:       input.Category,
 		Tags:           input.Tags,
@@ -136,18 +157,24 @@ func (s *Store) CreateTouchpoint(input model.TouchpointInput) (model.Touchpoint,
 	}
 
 	tps = append(tps, tp)
-	if err := s.saveTouchpoints(tps); err != nil {
+	if err := s.saveChange(tps, actionCreate, author, model.Touchpoint{}, tp); err != nil {
 		return model.Touchpoint{}, err
 	}
-
+	s.onChange.notify(model.EventTouchpointCreated, tp)
 	return tp, nil
 }
 
-func (s *Store) UpdateTouchpoint(id string, input model.TouchpointInput) (model.Touchpoint, error) {
-	s.sanitizeInput(&input)
+func (s *Store) UpdateTouchpoint(id string, input model.TouchpointInput, author string) (model.Touchpoint, error) {
+	sanitizeInput(s.sanitizer, &input)
+	if err := normalizeDate(&input, s.tolerance); err != nil {
+		return model.Touchpoint{}, err
+	}
 
 	s.tpMu.Lock()
 	defer s.tpMu.Unlock()
+	if err := s.reloadChanged(); err != nil {
+		return model.Touchpoint{}, err
+	}
 
 	s.mdMu.RLock()
 	err := s.validateInput(input)
@@ -156,51 +183,49 @@ func (s *Store) UpdateTouchpoint(id string, input model.TouchpointInput) (model.
 		return model.Touchpoint{}, err
 	}
 
-	tps, err := s.loadTouchpoints()
-	if err != nil {
-		return model.Touchpoint{}, err
+	i, ok := s.tps.byID[id]
+	if !ok || s.tps.all[i].DeletedAt != "" {
+		return model.Touchpoint{}, notFoundErr("touchpoint", id)
 	}
 
-	for i, tp := range tps {
-		if tp.ID == id {
-			tps[i].Description = input.Description
-			tps[i].Category = input.Category
-			tps[i].Tags = input.Tags
-			tps[i].PeopleInvolved = input.PeopleInvolved
-			tps[i].URL = input.URL
-
-			if err := s.saveTouchpoints(tps); err != nil {
-				return model.Touchpoint{}, err
-			}
-			return tps[i], nil
-		}
+	tps := s.touchpoints()
+	before := tps[i]
+	if input.Date != "" {
+		tps[i].Date = input.Date
 	}
+	tps[i].Description = input.Description
+	tps[i].Category = input.Category
+	tps[i].Tags = input.Tags
+	tps[i].PeopleInvolved = input.PeopleInvolved
+	tps[i].URL = input.URL
 
-	return model.Touchpoint{}, notFoundErr("touchpoint", id)
+	if err := s.saveChange(tps, actionUpdate, author, before, tps[i]); err != nil {
+		return model.Touchpoint{}, err
+	}
+	s.onChange.notify(model.EventTouchpointUpdated, tps[i])
+	return tps[i], nil
 }
 
-func (s *Store) DeleteTouchpoint(id string) error {
+// DeleteTouchpoint moves a touchpoint to the trash; PurgeTouchpoint and
+// PurgeTrash remove it for good.
+func (s *Store) DeleteTouchpoint(id, author string) error {
 	s.tpMu.Lock()
 	defer s.tpMu.Unlock()
-
-	tps, err := s.loadTouchpoints()
-	if err != nil {
+	if err := s.reloadChanged(); err != nil {
 		return err
 	}
 
-	filtered := make([]model.Touchpoint, 0, len(tps))
-	found := false
-	for _, tp := range tps {
-		if tp.ID == id {
-			found = true
-			continue
-		}
-		filtered = append(filtered, tp)
-	}
-
-	if !found {
+	i, ok := s.tps.byID[id]
+	if !ok || s.tps.all[i].DeletedAt != "" {
 		return notFoundErr("touchpoint", id)
 	}
 
-	return s.saveTouchpoints(filtered)
+	tps := s.touchpoints()
+	before := tps[i]
+	tps[i].DeletedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := s.saveChange(tps, actionDelete, author, before, model.Touchpoint{}); err != nil {
+		return err
+	}
+	s.onChange.notify(model.EventTouchpointDeleted, map[string]string{"id": id})
+	return nil
 }
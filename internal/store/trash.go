@@ -0,0 +1,111 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// trashedBefore reports whether a trashed touchpoint was deleted before the
+// cutoff. Unparseable timestamps are treated as expired.
+func trashedBefore(tp model.Touchpoint, cutoff time.Time) bool {
+	t, err := time.Parse(time.RFC3339, tp.DeletedAt)
+	return err != nil || t.Before(cutoff)
+}
+
+// sortTrash orders trashed touchpoints most recently deleted first.
+func sortTrash(tps []model.Touchpoint) {
+	sort.SliceStable(tps, func(i, j int) bool {
+		return tps[i].DeletedAt > tps[j].DeletedAt
+	})
+}
+
+func (s *Store) ListTrash() ([]model.Touchpoint, error) {
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	s.tpMu.RLock()
+	defer s.tpMu.RUnlock()
+
+	result := make([]model.Touchpoint, 0, len(s.tps.trash))
+	for _, i := range s.tps.trash {
+		result = append(result, s.tps.all[i])
+	}
+	sortTrash(result)
+	return result, nil
+}
+
+func (s *Store) RestoreTouchpoint(id, author string) (model.Touchpoint, error) {
+	s.tpMu.Lock()
+	defer s.tpMu.Unlock()
+	if err := s.reloadChanged(); err != nil {
+		return model.Touchpoint{}, err
+	}
+
+	i, ok := s.tps.byID[id]
+	if !ok || s.tps.all[i].DeletedAt == "" {
+		return model.Touchpoint{}, notFoundErr("trashed touchpoint", id)
+	}
+
+	tps := s.touchpoints()
+	before := tps[i]
+	tps[i].DeletedAt = ""
+	if err := s.saveChange(tps, actionRestore, author, before, tps[i]); err != nil {
+		return model.Touchpoint{}, err
+	}
+	// To anyone watching, coming back from the trash is the same as being
+	// created again.
+	s.onChange.notify(model.EventTouchpointCreated, tps[i])
+	return tps[i], nil
+}
+
+func (s *Store) PurgeTouchpoint(id string) error {
+	s.tpMu.Lock()
+	defer s.tpMu.Unlock()
+	if err := s.reloadChanged(); err != nil {
+		return err
+	}
+
+	i, ok := s.tps.byID[id]
+	if !ok || s.tps.all[i].DeletedAt == "" {
+		return notFoundErr("trashed touchpoint", id)
+	}
+
+	tps := s.touchpoints()
+	if err := s.saveTouchpoints(slices.Delete(tps, i, i+1)); err != nil {
+		return err
+	}
+	return s.dropHistory([]string{id})
+}
+
+// PurgeTrash permanently removes touchpoints trashed before the cutoff and
+// returns how many were removed.
+func (s *Store) PurgeTrash(before time.Time) (int, error) {
+	s.tpMu.Lock()
+	defer s.tpMu.Unlock()
+	if err := s.reloadChanged(); err != nil {
+		return 0, err
+	}
+
+	var purged []string
+	tps := slices.DeleteFunc(s.touchpoints(), func(tp model.Touchpoint) bool {
+		if tp.DeletedAt != "" && trashedBefore(tp, before) {
+			purged = append(purged, tp.ID)
+			return true
+		}
+		return false
+	})
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	if err := s.saveTouchpoints(tps); err != nil {
+		return 0, err
+	}
+	if err := s.dropHistory(purged); err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+func TestTrashLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend) {
+		tps := createFixtures(t, s)
+		login, docs := tps[0].ID, tps[3].ID
+
+		for _, id := range []string{login, docs} {
+			if err := s.DeleteTouchpoint(id, "ada"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.DeleteTouchpoint(login, "ada"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("deleting a trashed touchpoint: %v, want ErrNotFound", err)
+		}
+		if _, err := s.UpdateTouchpoint(login, listFixtures[0], "ada"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("updating a trashed touchpoint: %v, want ErrNotFound", err)
+		}
+		if _, err := s.RestoreRevision(login, 1, "ada"); !errors.Is(err, ErrValidation) {
+			t.Errorf("restoring a revision of a trashed touchpoint: %v, want ErrValidation", err)
+		}
+
+		page, err := s.ListTouchpoints(model.TouchpointFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Touchpoints) != len(listFixtures)-2 {
+			t.Errorf("%d touchpoints listed after deleting 2 of %d", len(page.Touchpoints), len(listFixtures))
+		}
+		trash, err := s.ListTrash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(trash) != 2 {
+			t.Fatalf("trash holds %d touchpoints, want 2", len(trash))
+		}
+		for _, tp := range trash {
+			if tp.DeletedAt == "" {
+				t.Errorf("trashed %s has no deletion time", tp.ID)
+			}
+		}
+		if c, err := s.Counts(); err != nil || c.Touchpoints != len(listFixtures)-2 || c.Trashed != 2 {
+			t.Errorf("counts %+v, %v", c, err)
+		}
+
+		// Restoring brings it back as it was, and records the restore.
+		restored, err := s.RestoreTouchpoint(login, "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.DeletedAt != "" || restored.Description != listFixtures[0].Description {
+			t.Errorf("restored %+v", restored)
+		}
+		revs, err := s.TouchpointHistory(login)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := revs[len(revs)-1]; last.Action != actionRestore || last.Author != "bob" {
+			t.Errorf("restore recorded %+v", last)
+		}
+		if _, err := s.RestoreTouchpoint(login, "bob"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("restoring a live touchpoint: %v, want ErrNotFound", err)
+		}
+
+		// Only trashed touchpoints can be purged, and purging drops their
+		// history too.
+		if err := s.PurgeTouchpoint(login); !errors.Is(err, ErrNotFound) {
+			t.Errorf("purging a live touchpoint: %v, want ErrNotFound", err)
+		}
+		if err := s.PurgeTouchpoint(docs); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PurgeTouchpoint(docs); !errors.Is(err, ErrNotFound) {
+			t.Errorf("purging twice: %v, want ErrNotFound", err)
+		}
+		if _, err := s.TouchpointHistory(docs); !errors.Is(err, ErrNotFound) {
+			t.Errorf("history of a purged touchpoint: %v, want ErrNotFound", err)
+		}
+		if _, err := s.RestoreTouchpoint(docs, "bob"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("restoring a purged touchpoint: %v, want ErrNotFound", err)
+		}
+		if trash, err := s.ListTrash(); err != nil || len(trash) != 0 {
+			t.Errorf("trash %+v, %v", trash, err)
+		}
+	})
+}
+
+func TestPurgeTrash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend) {
+		tps := createFixtures(t, s)
+		for _, i := range []int{0, 1} {
+			if err := s.DeleteTouchpoint(tps[i].ID, "ada"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Nothing was trashed an hour ago.
+		n, err := s.PurgeTrash(time.Now().Add(-time.Hour))
+		if err != nil || n != 0 {
+			t.Fatalf("purged %d, %v; want 0", n, err)
+		}
+		n, err = s.PurgeTrash(time.Now().Add(time.Minute))
+		if err != nil || n != 2 {
+			t.Fatalf("purged %d, %v; want 2", n, err)
+		}
+		for _, i := range []int{0, 1} {
+			if _, err := s.TouchpointHistory(tps[i].ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("history of purged %s: %v, want ErrNotFound", tps[i].ID, err)
+			}
+		}
+		c, err := s.Counts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Touchpoints != len(listFixtures)-2 || c.Trashed != 0 {
+			t.Errorf("counts %+v after purging", c)
+		}
+	})
+}
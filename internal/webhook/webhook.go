@@ -0,0 +1,726 @@
+// Package webhook delivers change events to subscribed URLs as signed JSON
+// POST requests, retrying failures with exponential backoff and keeping a
+// log of every delivery.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrInvalid  = errors.New("invalid webhook")
+	ErrClosed   = errors.New("webhook dispatcher is closed")
+
+	// errLocalAddress fails deliveries to addresses refused without
+	// Options.AllowLocal.
+	errLocalAddress = errors.New("refusing to deliver to a loopback or link-local address")
+)
+
+// Headers set on every delivery. The signature is "sha256=" followed by the
+// hex HMAC-SHA256 of "<timestamp>.<body>", keyed by the webhook's secret.
+const (
+	EventHeader     = "X-Ohara-Event"
+	DeliveryHeader  = "X-Ohara-Delivery"
+	TimestampHeader = "X-Ohara-Timestamp"
+	SignatureHeader = "X-Ohara-Signature"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultBackoff     = 2 * time.Second
+	defaultTimeout     = 10 * time.Second
+	// maxDeliveries is how many deliveries are logged per webhook.
+	maxDeliveries = 50
+	// maxResponseBody is how much of a receiver's response is kept in the
+	// delivery log when it fails.
+	maxResponseBody = 256
+)
+
+const (
+	statusPending   = "pending"
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+)
+
+// Sign returns the signature header value for a delivery body sent at
+// timestamp, for receivers to compare against with hmac.Equal.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Options tune delivery; zero values pick the defaults.
+type Options struct {
+	// MaxAttempts is how many times a delivery is tried, 8 by default.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubling after each
+	// attempt; 2s by default, so 8 attempts span about four minutes.
+	Backoff time.Duration
+	// Client sends deliveries. By default each attempt times out after 10s
+	// and connects directly, ignoring proxy settings, so that the address
+	// it reaches can be checked against AllowLocal.
+	Client *http.Client
+	// UserAgent is sent with deliveries.
+	UserAgent string
+	// AllowLocal lets webhooks reach loopback and link-local addresses,
+	// which are otherwise refused so that webhooks cannot probe the host
+	// or cloud metadata services. It only affects the default Client.
+	AllowLocal bool
+}
+
+// hookRecord is a webhook and the namespace whose events it receives.
+type hookRecord struct {
+	model.Webhook
+	Owner string `json:"owner,omitempty"`
+}
+
+// Dispatcher keeps webhooks in <data-dir>/webhooks.json and their delivery
+// log in <data-dir>/webhook-deliveries.json, and sends events to them.
+// Retries are held in memory, so deliveries pending when the process stops
+// are logged as failed. The delivery log is saved in the background, so
+// publishing never waits on the disk.
+//
+// Several processes may share a data directory, such as a server and
+// ohara import. Each rereads a file once another has saved it, and each
+// keeps its own deliveries in the log, taking the rest from the file.
+type Dispatcher struct {
+	hooksPath      string
+	deliveriesPath string
+	opts           Options
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// dirty asks the saver to write the delivery log; stop and saved end
+	// it once everything else has finished.
+	dirty chan struct{}
+	stop  chan struct{}
+	saved chan struct{}
+
+	mu         sync.Mutex
+	closed     bool
+	hooks      []hookRecord
+	deliveries []model.WebhookDelivery
+	// own holds the IDs of the deliveries this process made.
+	own map[string]bool
+	// hooksFile and deliveriesFile are the versions of the files last
+	// read or written here.
+	hooksFile      os.FileInfo
+	deliveriesFile os.FileInfo
+}
+
+func New(dataDir string, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultBackoff
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: defaultTimeout, Transport: newTransport(opts.AllowLocal)}
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "ohara-webhook"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		hooksPath:      filepath.Join(dataDir, "webhooks.json"),
+		deliveriesPath: filepath.Join(dataDir, "webhook-deliveries.json"),
+		opts:           opts,
+		ctx:            ctx,
+		cancel:         cancel,
+		dirty:          make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+		saved:          make(chan struct{}),
+		own:            map[string]bool{},
+	}
+	go d.saver()
+	return d
+}
+
+// Close abandons pending retries, waits for deliveries in flight to be
+// logged and saves the log. Publishing afterwards does nothing.
+func (d *Dispatcher) Close() {
+	d.CloseAfter(0)
+}
+
+// CloseAfter is Close, but first gives deliveries up to grace to finish,
+// retries included, for commands that exit right after making changes.
+func (d *Dispatcher) CloseAfter(grace time.Duration) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	if grace > 0 {
+		done := make(chan struct{})
+		go func() {
+			d.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(grace):
+		}
+	}
+	d.cancel()
+	d.wg.Wait()
+	close(d.stop)
+	<-d.saved
+}
+
+// saver writes the delivery log whenever it has changed, folding changes
+// made during a write into the next one, and once more on Close.
+func (d *Dispatcher) saver() {
+	defer close(d.saved)
+	for {
+		select {
+		case <-d.dirty:
+			d.saveDeliveries()
+		case <-d.stop:
+			select {
+			case <-d.dirty:
+				d.saveDeliveries()
+			default:
+			}
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) saveDeliveries() {
+	d.mu.Lock()
+	// Take in what other processes have logged, so as not to overwrite it.
+	err := d.load()
+	deliveries := slices.Clone(d.deliveries)
+	d.mu.Unlock()
+	if err == nil {
+		err = writeJSON(d.deliveriesPath, deliveries)
+	}
+	if err != nil {
+		// The deliveries themselves went ahead; only their log is stale.
+		log.Error().Str("package", "webhook").Err(err).Msg("Failed to save the webhook delivery log")
+		return
+	}
+	d.mu.Lock()
+	d.deliveriesFile = statFile(d.deliveriesPath)
+	d.mu.Unlock()
+}
+
+// changed asks the saver to write the delivery log.
+func (d *Dispatcher) changed() {
+	select {
+	case d.dirty <- struct{}{}:
+	default:
+		// A save is already due and will include this change.
+	}
+}
+
+// load reads whichever files another process, or a previous one, has saved
+// since this one last read or wrote them. Callers must hold mu.
+func (d *Dispatcher) load() error {
+	// Stat first: a save landing in between is then read again next time
+	// rather than missed.
+	if info := statFile(d.hooksPath); !sameVersion(d.hooksFile, info) {
+		var hooks []hookRecord
+		if err := readJSON(d.hooksPath, &hooks); err != nil {
+			return err
+		}
+		d.hooks, d.hooksFile = hooks, info
+	}
+	if info := statFile(d.deliveriesPath); !sameVersion(d.deliveriesFile, info) {
+		var logged []model.WebhookDelivery
+		if err := readJSON(d.deliveriesPath, &logged); err != nil {
+			return err
+		}
+		d.mergeDeliveries(logged)
+		d.deliveriesFile = info
+	}
+	return nil
+}
+
+// mergeDeliveries replaces the deliveries other processes made with those
+// in logged, keeping this process's own, and drops any whose webhook has
+// been deleted. Callers must hold mu.
+func (d *Dispatcher) mergeDeliveries(logged []model.WebhookDelivery) {
+	// Pending deliveries not updated for longer than any retry waits were
+	// left by a process that has stopped.
+	stale := time.Now().Add(-(d.opts.Backoff<<d.opts.MaxAttempts + defaultTimeout)).UTC().Format(time.RFC3339)
+	merged := make([]model.WebhookDelivery, 0, len(logged)+len(d.own))
+	for _, dl := range logged {
+		if d.own[dl.ID] {
+			continue
+		}
+		if dl.Status == statusPending && dl.UpdatedAt < stale {
+			dl.Status = statusFailed
+			dl.Error = "interrupted by a restart"
+		}
+		merged = append(merged, dl)
+	}
+	for _, dl := range d.deliveries {
+		if d.own[dl.ID] {
+			merged = append(merged, dl)
+		}
+	}
+	merged = slices.DeleteFunc(merged, func(dl model.WebhookDelivery) bool {
+		return !slices.ContainsFunc(d.hooks, func(h hookRecord) bool { return h.ID == dl.WebhookID })
+	})
+	slices.SortStableFunc(merged, func(a, b model.WebhookDelivery) int {
+		return strings.Compare(a.CreatedAt, b.CreatedAt)
+	})
+	d.deliveries = merged
+	for _, h := range d.hooks {
+		d.trim(h.ID)
+	}
+}
+
+func (d *Dispatcher) find(owner, id string) int {
+	return slices.IndexFunc(d.hooks, func(h hookRecord) bool { return h.ID == id && h.Owner == owner })
+}
+
+// Create saves a webhook receiving owner's events and returns it with its
+// secret.
+func (d *Dispatcher) Create(owner string, req model.WebhookRequest, createdBy string) (model.Webhook, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return model.Webhook{}, fmt.Errorf("url %q must be an absolute http or https URL: %w", req.URL, ErrInvalid)
+	}
+	if !d.opts.AllowLocal && isLocalHost(u.Hostname()) {
+		return model.Webhook{}, fmt.Errorf("url %q points at a loopback or link-local address: %w", req.URL, ErrInvalid)
+	}
+	for _, ev := range req.Events {
+		if !slices.Contains(model.EventTypes, ev) {
+			return model.Webhook{}, fmt.Errorf("unknown event %q: %w", ev, ErrInvalid)
+		}
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret = "whsec_" + randomHex(24)
+	}
+
+	hook := model.Webhook{
+		ID:          randomHex(8),
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		Secret:      secret,
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return model.Webhook{}, err
+	}
+	hooks := append(slices.Clone(d.hooks), hookRecord{Webhook: hook, Owner: owner})
+	if err := writeJSON(d.hooksPath, hooks); err != nil {
+		return model.Webhook{}, err
+	}
+	d.hooks, d.hooksFile = hooks, statFile(d.hooksPath)
+	return hook, nil
+}
+
+// List returns owner's webhooks without their secrets.
+func (d *Dispatcher) List(owner string) ([]model.Webhook, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	hooks := []model.Webhook{}
+	for _, h := range d.hooks {
+		if h.Owner == owner {
+			h.Secret = ""
+			hooks = append(hooks, h.Webhook)
+		}
+	}
+	return hooks, nil
+}
+
+// Delete removes one of owner's webhooks along with its delivery log, and
+// stops any retries for it.
+func (d *Dispatcher) Delete(owner, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return err
+	}
+	i := d.find(owner, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	hooks := slices.Delete(slices.Clone(d.hooks), i, i+1)
+	if err := writeJSON(d.hooksPath, hooks); err != nil {
+		return err
+	}
+	d.hooks, d.hooksFile = hooks, statFile(d.hooksPath)
+	d.deliveries = slices.DeleteFunc(d.deliveries, func(dl model.WebhookDelivery) bool {
+		if dl.WebhookID != id {
+			return false
+		}
+		delete(d.own, dl.ID)
+		return true
+	})
+	d.changed()
+	return nil
+}
+
+// Deliveries returns the delivery log of one of owner's webhooks, newest
+// first.
+func (d *Dispatcher) Deliveries(owner, id string) ([]model.WebhookDelivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	if d.find(owner, id) < 0 {
+		return nil, ErrNotFound
+	}
+	out := []model.WebhookDelivery{}
+	for _, dl := range slices.Backward(d.deliveries) {
+		if dl.WebhookID == id {
+			out = append(out, dl)
+		}
+	}
+	return out, nil
+}
+
+// NewEvent stamps an event of the given type with an ID and the time.
+func NewEvent(typ string, data any) model.Event {
+	return model.Event{
+		ID:        randomHex(16),
+		Type:      typ,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Data:      data,
+	}
+}
+
+// Publish queues ev for every one of owner's webhooks subscribed to it and
+// returns without waiting for the deliveries. After Close it does nothing.
+func (d *Dispatcher) Publish(owner string, ev model.Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	if err := d.load(); err != nil {
+		return err
+	}
+	for _, h := range d.hooks {
+		if h.Owner != owner || (len(h.Events) > 0 && !slices.Contains(h.Events, ev.Type)) {
+			continue
+		}
+		dl := d.logDelivery(h.ID, ev)
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(dl, body, d.opts.MaxAttempts)
+		}()
+	}
+	return nil
+}
+
+// Test sends a ping event to one of owner's webhooks once, without retries,
+// and returns the logged delivery.
+func (d *Dispatcher) Test(owner, id string) (model.WebhookDelivery, error) {
+	ev := NewEvent(model.EventPing, map[string]string{"webhook_id": id})
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return model.WebhookDelivery{}, ErrClosed
+	}
+	if err := d.load(); err != nil {
+		d.mu.Unlock()
+		return model.WebhookDelivery{}, err
+	}
+	if d.find(owner, id) < 0 {
+		d.mu.Unlock()
+		return model.WebhookDelivery{}, ErrNotFound
+	}
+	dl := d.logDelivery(id, ev)
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	defer d.wg.Done()
+	return d.deliver(dl, body, 1), nil
+}
+
+// logDelivery appends a pending delivery to the log, dropping the webhook's
+// oldest beyond maxDeliveries. Callers must hold mu.
+func (d *Dispatcher) logDelivery(hookID string, ev model.Event) model.WebhookDelivery {
+	now := time.Now().UTC().Format(time.RFC3339)
+	dl := model.WebhookDelivery{
+		ID:        randomHex(16),
+		WebhookID: hookID,
+		EventID:   ev.ID,
+		Event:     ev.Type,
+		Status:    statusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	d.deliveries = append(d.deliveries, dl)
+	d.own[dl.ID] = true
+	d.trim(hookID)
+	d.changed()
+	return dl
+}
+
+// trim drops a webhook's oldest deliveries beyond maxDeliveries. Callers
+// must hold mu.
+func (d *Dispatcher) trim(hookID string) {
+	n := 0
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		if d.deliveries[i].WebhookID != hookID {
+			continue
+		}
+		if n++; n > maxDeliveries {
+			delete(d.own, d.deliveries[i].ID)
+			d.deliveries = slices.Delete(d.deliveries, i, i+1)
+		}
+	}
+}
+
+// deliver makes up to attempts tries at sending body, waiting Backoff,
+// then twice as long, and so on between them, and returns the final state
+// of the delivery.
+func (d *Dispatcher) deliver(dl model.WebhookDelivery, body []byte, attempts int) model.WebhookDelivery {
+	wait := d.opts.Backoff
+	for {
+		hook, ok := d.hook(dl.WebhookID)
+		if !ok {
+			// Deleted along with its log while retrying.
+			return dl
+		}
+		status, err := d.send(hook, dl, body)
+		dl.Attempts++
+		dl.ResponseStatus = status
+		dl.Error = ""
+		if err != nil {
+			dl.Error = err.Error()
+		}
+		switch {
+		case err == nil:
+			dl.Status = statusSucceeded
+		case !retryable(status) || errors.Is(err, errLocalAddress) || dl.Attempts >= attempts:
+			dl.Status = statusFailed
+			log.Warn().Str("package", "webhook").Str("webhook", dl.WebhookID).Str("delivery", dl.ID).
+				Int("attempts", dl.Attempts).Str("error", dl.Error).Msg("Webhook delivery failed")
+		}
+		d.update(dl)
+		if dl.Status != statusPending {
+			return dl
+		}
+
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-d.ctx.Done():
+			dl.Status = statusFailed
+			dl.Error += "; retries abandoned at shutdown"
+			d.update(dl)
+			return dl
+		}
+	}
+}
+
+// retryable reports whether a failure may go away by itself: the receiver
+// could not be reached, timed out, was rate limiting or had a server error.
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+func (d *Dispatcher) hook(id string) (model.Webhook, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		log.Error().Str("package", "webhook").Err(err).Msg("Failed to reload webhooks")
+	}
+	i := slices.IndexFunc(d.hooks, func(h hookRecord) bool { return h.ID == id })
+	if i < 0 {
+		return model.Webhook{}, false
+	}
+	return d.hooks[i].Webhook, true
+}
+
+// send makes one attempt and returns the response status, or an error for
+// anything but a 2xx.
+func (d *Dispatcher) send(hook model.Webhook, dl model.WebhookDelivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", d.opts.UserAgent)
+	req.Header.Set(EventHeader, dl.Event)
+	req.Header.Set(DeliveryHeader, dl.ID)
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, ts, body))
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	msg := "receiver answered " + resp.Status
+	if len(bytes.TrimSpace(snippet)) > 0 {
+		msg += ": " + string(bytes.TrimSpace(snippet))
+	}
+	return resp.StatusCode, errors.New(msg)
+}
+
+// update saves a delivery's progress to the log, unless it has been
+// dropped from it meanwhile.
+func (d *Dispatcher) update(dl model.WebhookDelivery) {
+	dl.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := slices.IndexFunc(d.deliveries, func(x model.WebhookDelivery) bool { return x.ID == dl.ID })
+	if i < 0 {
+		return
+	}
+	d.deliveries[i] = dl
+	d.changed()
+}
+
+// newTransport connects to receivers directly, refusing loopback and
+// link-local addresses unless allowLocal is set. The check runs on the
+// address actually dialled, so host names resolving to them are refused
+// too.
+func newTransport(allowLocal bool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	if !allowLocal {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if isLocalHost(host) {
+					return errLocalAddress
+				}
+				return nil
+			},
+		}
+		t.DialContext = dialer.DialContext
+	}
+	return t
+}
+
+// isLocalHost reports whether host is localhost or a loopback, link-local
+// or unspecified IP address. Other names are left to the dial-time check.
+func isLocalHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statFile returns nil for files that do not exist yet.
+func statFile(path string) os.FileInfo {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// sameVersion reports whether two stats are of the same save of a file.
+// writeJSON renames a new file into place, so every save changes the
+// file's identity.
+func sameVersion(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// readJSON decodes path into v, leaving v alone if the file does not exist.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON saves v atomically with owner-only permissions, since webhook
+// secrets are stored in the clear to sign with.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
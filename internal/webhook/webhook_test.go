@@ -0,0 +1,294 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tanq16/ohara/internal/model"
+)
+
+// receiver answers with the given statuses in turn, then 200, and records
+// the events it was sent after checking their signatures.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	statuses []int
+	calls    atomic.Int32
+	events   chan model.Event
+}
+
+func newReceiver(t *testing.T, secret string, statuses ...int) (*receiver, *httptest.Server) {
+	rc := &receiver{t: t, secret: secret, statuses: statuses, events: make(chan model.Event, 10)}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	return rc, srv
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	want := Sign(rc.secret, r.Header.Get(TimestampHeader), body)
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get(SignatureHeader))) {
+		rc.t.Errorf("signature %q, want %q", r.Header.Get(SignatureHeader), want)
+	}
+	var ev model.Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		rc.t.Errorf("body: %v", err)
+	}
+	if got := r.Header.Get(EventHeader); got != ev.Type {
+		rc.t.Errorf("%s %q, want %q", EventHeader, got, ev.Type)
+	}
+	if r.Header.Get(DeliveryHeader) == "" {
+		rc.t.Errorf("no %s header", DeliveryHeader)
+	}
+
+	if n := int(rc.calls.Add(1)); n <= len(rc.statuses) {
+		http.Error(w, "try again", rc.statuses[n-1])
+		return
+	}
+	rc.events <- ev
+}
+
+func newDispatcher(t *testing.T, attempts int) *Dispatcher {
+	d := New(t.TempDir(), Options{MaxAttempts: attempts, Backoff: 10 * time.Millisecond, AllowLocal: true})
+	t.Cleanup(d.Close)
+	return d
+}
+
+func createHook(t *testing.T, d *Dispatcher, owner, url string, events ...string) model.Webhook {
+	t.Helper()
+	hook, err := d.Create(owner, model.WebhookRequest{URL: url, Events: events, Secret: "s3cret"}, "tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hook
+}
+
+// waitDelivery polls the log until the webhook's latest delivery is done.
+func waitDelivery(t *testing.T, d *Dispatcher, owner, id string) model.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := d.Deliveries(owner, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) > 0 && deliveries[0].Status != statusPending {
+			return deliveries[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("delivery did not finish")
+	return model.WebhookDelivery{}
+}
+
+func TestPublishRetriesUntilDelivered(t *testing.T) {
+	rc, srv := newReceiver(t, "s3cret", http.StatusServiceUnavailable, http.StatusInternalServerError)
+	d := newDispatcher(t, 5)
+	hook := createHook(t, d, "", srv.URL)
+
+	ev := NewEvent(model.EventTouchpointCreated, model.Touchpoint{ID: "t1"})
+	if err := d.Publish("", ev); err != nil {
+		t.Fatal(err)
+	}
+	got := <-rc.events
+	if got.ID != ev.ID || got.Type != model.EventTouchpointCreated {
+		t.Errorf("received %+v, want event %s", got, ev.ID)
+	}
+
+	dl := waitDelivery(t, d, "", hook.ID)
+	if dl.Status != statusSucceeded || dl.Attempts != 3 || dl.ResponseStatus != http.StatusOK || dl.EventID != ev.ID {
+		t.Errorf("delivery %+v, want succeeded on the third attempt", dl)
+	}
+}
+
+func TestPublishGivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+	}{
+		{"client error is final", []int{http.StatusBadRequest}, 1},
+		{"attempts run out", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, srv := newReceiver(t, "s3cret", tt.statuses...)
+			d := newDispatcher(t, 3)
+			hook := createHook(t, d, "", srv.URL)
+
+			if err := d.Publish("", NewEvent(model.EventTouchpointDeleted, nil)); err != nil {
+				t.Fatal(err)
+			}
+			dl := waitDelivery(t, d, "", hook.ID)
+			if dl.Status != statusFailed || dl.Attempts != tt.attempts || dl.ResponseStatus != tt.statuses[0] || dl.Error == "" {
+				t.Errorf("delivery %+v, want failed after %d attempts", dl, tt.attempts)
+			}
+			if n := int(rc.calls.Load()); n != tt.attempts {
+				t.Errorf("receiver called %d times, want %d", n, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestPublishMatchesOwnerAndEvents(t *testing.T) {
+	_, srv := newReceiver(t, "s3cret")
+	d := newDispatcher(t, 1)
+	reports := createHook(t, d, "", srv.URL, model.EventReportPublished)
+	other := createHook(t, d, "alice", srv.URL)
+
+	if err := d.Publish("", NewEvent(model.EventTouchpointCreated, nil)); err != nil {
+		t.Fatal(err)
+	}
+	for owner, hook := range map[string]model.Webhook{"": reports, "alice": other} {
+		deliveries, err := d.Deliveries(owner, hook.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 0 {
+			t.Errorf("webhook %s got %d deliveries, want none", hook.ID, len(deliveries))
+		}
+	}
+}
+
+func TestTestSendsPing(t *testing.T) {
+	rc, srv := newReceiver(t, "s3cret")
+	d := newDispatcher(t, 5)
+	hook := createHook(t, d, "alice", srv.URL)
+
+	if _, err := d.Test("", hook.ID); err != ErrNotFound {
+		t.Errorf("Test from another namespace: %v, want ErrNotFound", err)
+	}
+	dl, err := d.Test("alice", hook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dl.Status != statusSucceeded || dl.Event != model.EventPing {
+		t.Errorf("delivery %+v, want a succeeded ping", dl)
+	}
+	if ev := <-rc.events; ev.Type != model.EventPing {
+		t.Errorf("received %q, want ping", ev.Type)
+	}
+
+	hooks, err := d.List("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].Secret != "" {
+		t.Errorf("List = %+v, want one webhook without its secret", hooks)
+	}
+}
+
+func TestPublishAfterCloseLogsNothing(t *testing.T) {
+	rc, srv := newReceiver(t, "s3cret")
+	dir := t.TempDir()
+	d := New(dir, Options{MaxAttempts: 1, AllowLocal: true})
+	hook := createHook(t, d, "", srv.URL)
+
+	if err := d.Publish("", NewEvent(model.EventTouchpointCreated, nil)); err != nil {
+		t.Fatal(err)
+	}
+	<-rc.events
+	waitDelivery(t, d, "", hook.ID)
+	d.Close()
+	if err := d.Publish("", NewEvent(model.EventTouchpointDeleted, nil)); err != nil {
+		t.Errorf("Publish after Close: %v", err)
+	}
+	if _, err := d.Test("", hook.ID); err != ErrClosed {
+		t.Errorf("Test after Close: %v, want ErrClosed", err)
+	}
+
+	// Close saved the log, with only the delivery from before it.
+	data, err := os.ReadFile(filepath.Join(dir, "webhook-deliveries.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deliveries []model.WebhookDelivery
+	if err := json.Unmarshal(data, &deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != statusSucceeded {
+		t.Errorf("saved log %+v, want one succeeded delivery", deliveries)
+	}
+}
+
+func TestLocalTargetsRefused(t *testing.T) {
+	d := New(t.TempDir(), Options{})
+	t.Cleanup(d.Close)
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/",
+	} {
+		_, err := d.Create("", model.WebhookRequest{URL: target}, "tester")
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("Create(%s): %v, want ErrInvalid", target, err)
+		}
+	}
+
+	// Names are checked once resolved, when dialling.
+	_, srv := newReceiver(t, "s3cret")
+	client := &http.Client{Transport: newTransport(false)}
+	if _, err := client.Post(srv.URL, "application/json", nil); !errors.Is(err, errLocalAddress) {
+		t.Errorf("POST to %s: %v, want errLocalAddress", srv.URL, err)
+	}
+}
+
+// TestProcessesShareFiles runs two dispatchers on one directory, as a
+// server and ohara import would.
+func TestProcessesShareFiles(t *testing.T) {
+	rc, srv := newReceiver(t, "s3cret")
+	dir := t.TempDir()
+	server := New(dir, Options{MaxAttempts: 1, AllowLocal: true})
+	cli := New(dir, Options{MaxAttempts: 1, AllowLocal: true})
+	defer server.Close()
+
+	// The server sees a webhook created after it first read the file.
+	if _, err := server.List(""); err != nil {
+		t.Fatal(err)
+	}
+	hook := createHook(t, cli, "", srv.URL)
+	hooks, err := server.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].ID != hook.ID {
+		t.Fatalf("server lists %+v, want the webhook made elsewhere", hooks)
+	}
+
+	for _, d := range []*Dispatcher{server, cli} {
+		if err := d.Publish("", NewEvent(model.EventTouchpointCreated, nil)); err != nil {
+			t.Fatal(err)
+		}
+		<-rc.events
+		waitDelivery(t, d, "", hook.ID)
+	}
+	cli.Close()
+
+	// Neither process's log overwrote the other's.
+	deliveries, err := server.Deliveries("", hook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("server has %d deliveries, want 2", len(deliveries))
+	}
+	server.Close()
+	var saved []model.WebhookDelivery
+	if err := readJSON(filepath.Join(dir, "webhook-deliveries.json"), &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 {
+		t.Errorf("saved %d deliveries, want 2", len(saved))
+	}
+}